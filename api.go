@@ -1,6 +1,7 @@
 package html2html
 
 import (
+	"bytes"
 	"io"
 
 	"golang.org/x/net/html"
@@ -26,6 +27,83 @@ var VoidElements = []string{
 	"wbr",
 }
 
+// BlockElements are rendered as blocks, so there is a line break around them.
+// see https://developer.mozilla.org/en-US/docs/Web/HTML/Block-level_elements
+var BlockElements = []string{
+	"address",
+	"article",
+	"aside",
+	"blockquote",
+	"body",
+	"dd",
+	"details",
+	"dialog",
+	"div",
+	"dl",
+	"dt",
+	"fieldset",
+	"figcaption",
+	"figure",
+	"footer",
+	"form",
+	"h1",
+	"h2",
+	"h3",
+	"h4",
+	"h5",
+	"h6",
+	"head",
+	"header",
+	"hgroup",
+	"hr",
+	"html",
+	"li",
+	"main",
+	"nav",
+	"ol",
+	"p",
+	"pre",
+	"section",
+	"summary",
+	"table",
+	"tbody",
+	"td",
+	"tfoot",
+	"th",
+	"thead",
+	"tr",
+	"ul",
+}
+
+// BooleanAttributes are true by their presence, the value is not meaningful.
+// see https://html.spec.whatwg.org/multipage/indices.html#attributes-3
+var BooleanAttributes = []string{
+	"allowfullscreen",
+	"async",
+	"autofocus",
+	"autoplay",
+	"checked",
+	"controls",
+	"default",
+	"defer",
+	"disabled",
+	"formnovalidate",
+	"inert",
+	"ismap",
+	"itemscope",
+	"loop",
+	"multiple",
+	"muted",
+	"nomodule",
+	"novalidate",
+	"open",
+	"playsinline",
+	"readonly",
+	"required",
+	"reversed",
+	"selected",
+}
+
 var _ TokenConsumer = &DefaultConsumer{}
 var _ TagAttrsConsumer = &DefaultConsumer{}
 
@@ -39,6 +117,16 @@ func IsVoidElement(tag Tag) bool {
 	return false
 }
 
+func IsBlockElement(tag Tag) bool {
+	for _, be := range BlockElements {
+		if tag.Name() == be {
+			return true
+		}
+	}
+
+	return false
+}
+
 // TokenConsumer はHTMLのTokenを食べて何らかの文字列を組み立てる
 type TokenConsumer interface {
 	// ConsumeToken は渡されたTokenをキリのいいところまで処理し、次に処理するべきTokenを返す
@@ -49,6 +137,11 @@ type TagAttrsConsumer interface {
 	ConsumeAttrs(tag Tag, token html.Token) error
 }
 
+// Serializer は Tag の木を文字列にする。Converter.Convert は設定されていれば BuildHTML の代わりにこれを使う
+type Serializer interface {
+	Serialize(buf *bytes.Buffer, token Token) error
+}
+
 type Converter interface {
 	DefaultConsumer() TokenConsumer
 	RaiseErrorOnInvalidEndTag() bool
@@ -61,16 +154,42 @@ type Converter interface {
 	SetTokenTypeConsumer(tokenType html.TokenType, consumer TokenConsumer)
 	SetTagNameConsumer(tagName string, consumer TokenConsumer)
 
+	// 1つのConverterは並列の変換には使えない。Parse は同時に1つずつしか実行されないので、
+	// 並列に変換する場合はgoroutineごとにConverterを作ること。設定の読み書きは変換中に他のgoroutineから行ってもよい。
+	// TokenConsumer が返したエラーは位置を付けた *ParseError に包むので、== ではなく errors.Is や errors.As で比べること。
 	Parse(r io.Reader) (Tag, error)
 	Convert(r io.Reader) (string, error)
 }
 
+// ConverterOptions は Converter の Parse と Convert の追加の設定。NewConverter が返す Converter はこれも満たす。
+//
+//	conv := NewConverter()
+//	conv.(ConverterOptions).SetSerializer(NewMinifier())
+type ConverterOptions interface {
+	// Pipeline は Convert が Parse と BuildHTML の間で実行する
+	Pipeline() *Pipeline
+	SetPipeline(pipeline *Pipeline)
+
+	Serializer() Serializer
+	SetSerializer(serializer Serializer)
+
+	// Lossless が true なら Parse は各Tokenの元の文字列を覚え、Convert は Serializer が無ければ
+	// 書き換えられていないTokenを元の文字列のまま出力する
+	Lossless() bool
+	SetLossless(lossless bool)
+
+	// RecordPositions が true なら Parse は各Tokenの元の文字列での位置を覚える。SourcePosition で取り出せる
+	RecordPositions() bool
+	SetRecordPositions(recordPositions bool)
+}
+
 func NewConverter() Converter {
 	var conv Converter = &defaultConverter{}
 	conv.SetDefaultConsumer(&DefaultConsumer{
 		conv: conv,
 	})
 	conv.SetRaiseErrorOnInvalidEndTag(true)
+	conv.(ConverterOptions).SetPipeline(NewPipeline())
 
 	return conv
 }
@@ -2,6 +2,7 @@ package html2html
 
 import (
 	"strings"
+	"sync"
 	"testing"
 )
 
@@ -207,3 +208,69 @@ func TestVacuumConsumer(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestConverterParse_errorPosition(t *testing.T) {
+	html := "<p>\n  <i>Hi!</b></p>"
+
+	_, err := NewConverter().Parse(strings.NewReader(html))
+	if err == nil {
+		t.Fatal("error expected")
+	}
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatal("unexpected", err)
+	}
+	if parseErr.Line != 2 || parseErr.Column != 9 {
+		t.Error("unexpected", parseErr.Line, parseErr.Column)
+	}
+	if v := err.Error(); v != "2:9: unexpected end tag: b, expected: i" {
+		t.Error("unexpected", v)
+	}
+}
+
+func TestConverter_concurrent(t *testing.T) {
+	// go test -race で設定の読み書きと変換が競合しないことを確かめる
+	conv := NewConverter()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				result, err := conv.Convert(strings.NewReader(`<p><script>x()</script><b>Hi!</b></p>`))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if result != `<p><script>x()</script><b>Hi!</b></p>` && result != `<p><b>Hi!</b></p>` {
+					t.Error("unexpected", result)
+					return
+				}
+			}
+		}()
+	}
+	for j := 0; j < 20; j++ {
+		if j%2 == 0 {
+			conv.SetTagNameConsumer("script", NewVacuumConsumer(conv))
+		} else {
+			conv.SetTagNameConsumer("script", nil)
+		}
+		conv.(ConverterOptions).SetSerializer(&BuildOptions{})
+	}
+	wg.Wait()
+}
+
+func TestConverterParse_errorPositionLong(t *testing.T) {
+	// 読み終えた内容を捨てた後も行と列を数え続ける
+	html := strings.Repeat("<p>日本語 <b>text</b>\n</p>", 2000) + "<p>\n  あ<i>Hi!</b></p>"
+
+	_, err := NewConverter().Parse(strings.NewReader(html))
+	parseErr, ok := err.(*ParseError)
+	if !ok {
+		t.Fatal("unexpected", err)
+	}
+	if parseErr.Line != 2002 || parseErr.Column != 10 || parseErr.Offset != len(html)-len("</b></p>") {
+		t.Error("unexpected", parseErr.Offset, parseErr.Line, parseErr.Column)
+	}
+}
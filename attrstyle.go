@@ -0,0 +1,174 @@
+package html2html
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// AttrQuote は属性値を囲む引用符
+type AttrQuote int
+
+const (
+	// AttrQuoteDefault は引用符がわからない(コードで追加した)属性。二重引用符で出力する
+	AttrQuoteDefault AttrQuote = iota
+	AttrQuoteDouble
+	AttrQuoteSingle
+	// AttrQuoteNone は引用符無しで書かれていた値
+	AttrQuoteNone
+)
+
+// AttrStyle は BuildHTMLWithOptions での属性の書き方
+type AttrStyle int
+
+const (
+	// AttrStyleDefault は BuildHTML と同じく二重引用符で囲む。空の値は Parse した HTML で alt="" のように書かれていた場合だけ出力する
+	AttrStyleDefault AttrStyle = iota
+	// AttrStylePreserve は Parse した HTML の引用符と、空の値を書いていたかを保つ
+	AttrStylePreserve
+	// AttrStyleCanonical は空の値も含めて全て二重引用符で囲む
+	AttrStyleCanonical
+)
+
+// buildAttrWithStyle は attr を style に従って出力する。値の中の引用符は文字参照にする
+func buildAttrWithStyle(buf *bytes.Buffer, attr *Attr, style AttrStyle) {
+	switch style {
+	case AttrStylePreserve:
+		buf.WriteString(attr.Key)
+		if attr.Value == "" && !attr.HasValue {
+			return
+		}
+
+		switch {
+		case attr.Quote == AttrQuoteSingle:
+			buf.WriteString("='")
+			buf.WriteString(strings.Replace(attr.Value, "'", "&#39;", -1))
+			buf.WriteString("'")
+		case attr.Quote == AttrQuoteNone && canUnquoteAttrValue(attr.Value):
+			buf.WriteString("=")
+			buf.WriteString(attr.Value)
+		default:
+			buildQuotedAttrValue(buf, attr.Value)
+		}
+
+	case AttrStyleCanonical:
+		buf.WriteString(attr.Key)
+		buildQuotedAttrValue(buf, attr.Value)
+
+	default:
+		buildAttr(buf, attr)
+	}
+}
+
+func buildQuotedAttrValue(buf *bytes.Buffer, value string) {
+	buf.WriteString(`="`)
+	buf.WriteString(strings.Replace(value, `"`, "&quot;", -1))
+	buf.WriteString(`"`)
+}
+
+// sortedAttrs は attrs を名前順に並べた新しいスライスを返す
+func sortedAttrs(attrs []*Attr) []*Attr {
+	sorted := append([]*Attr(nil), attrs...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Key < sorted[j].Key
+	})
+
+	return sorted
+}
+
+// recordAttrFormats は開始タグの raw から、tag の属性の引用符と値が書かれていたかを記録する。
+// raw が token のものでなければ何もしない。
+func recordAttrFormats(tag Tag, token html.Token, raw []byte) {
+	name, formats := scanRawAttrs(raw)
+	if name != token.Data {
+		return
+	}
+
+	for _, attr := range tag.Attrs() {
+		if format, ok := formats[attr.Key]; ok {
+			attr.Quote = format.Quote
+			attr.HasValue = format.HasValue
+		}
+	}
+}
+
+// scanRawAttrs は開始タグの raw から要素名と、属性名ごとに最初に書かれた属性の書き方を返す。
+// 名前は html.Tokenizer と同じく小文字にし、値は文字参照を展開しない。
+func scanRawAttrs(raw []byte) (string, map[string]Attr) {
+	if len(raw) < 2 || raw[0] != '<' {
+		return "", nil
+	}
+
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\f' || c == '\r'
+	}
+	pos := 1
+	skipSpace := func() {
+		for pos < len(raw) && isSpace(raw[pos]) {
+			pos++
+		}
+	}
+
+	for pos < len(raw) && !isSpace(raw[pos]) && raw[pos] != '/' && raw[pos] != '>' {
+		pos++
+	}
+	name := strings.ToLower(string(raw[1:pos]))
+
+	formats := make(map[string]Attr)
+	for pos < len(raw) {
+		skipSpace()
+		if pos < len(raw) && raw[pos] == '/' {
+			pos++
+			continue
+		}
+		if pos >= len(raw) || raw[pos] == '>' {
+			break
+		}
+
+		// 先頭の = は属性名の一部になる
+		start := pos
+		pos++
+		for pos < len(raw) && !isSpace(raw[pos]) && raw[pos] != '/' && raw[pos] != '>' && raw[pos] != '=' {
+			pos++
+		}
+		key := strings.ToLower(string(raw[start:pos]))
+
+		format := Attr{Key: key}
+		skipSpace()
+		if pos < len(raw) && raw[pos] == '=' {
+			pos++
+			skipSpace()
+			format.HasValue = true
+			if pos < len(raw) && (raw[pos] == '"' || raw[pos] == '\'') {
+				quote := raw[pos]
+				if quote == '"' {
+					format.Quote = AttrQuoteDouble
+				} else {
+					format.Quote = AttrQuoteSingle
+				}
+				pos++
+				start := pos
+				for pos < len(raw) && raw[pos] != quote {
+					pos++
+				}
+				format.Value = string(raw[start:pos])
+				pos++
+			} else {
+				format.Quote = AttrQuoteNone
+				start := pos
+				for pos < len(raw) && !isSpace(raw[pos]) && raw[pos] != '>' {
+					pos++
+				}
+				format.Value = string(raw[start:pos])
+			}
+		}
+
+		if _, ok := formats[key]; !ok {
+			formats[key] = format
+		}
+	}
+
+	return name, formats
+}
@@ -0,0 +1,74 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildOptions_attrStyle(t *testing.T) {
+	html := `<img src='a.png' alt="" data-x=1 hidden><input value = "say 'hi'" disabled>`
+
+	conv := NewConverter()
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root.GetElementsByTagName("img")[0].AddAttr("class", "")
+
+	expects := []struct {
+		opts     *BuildOptions
+		expected string
+	}{
+		{nil, `<img src="a.png" alt="" data-x="1" hidden class><input value="say 'hi'" disabled>`},
+		{&BuildOptions{AttrStyle: AttrStylePreserve}, `<img src='a.png' alt="" data-x=1 hidden class><input value="say 'hi'" disabled>`},
+		{&BuildOptions{AttrStyle: AttrStyleCanonical}, `<img src="a.png" alt="" data-x="1" hidden="" class=""><input value="say 'hi'" disabled="">`},
+		{&BuildOptions{AttrStyle: AttrStylePreserve, SortAttrs: true}, `<img alt="" class data-x=1 hidden src='a.png'><input disabled value="say 'hi'">`},
+	}
+	for _, expect := range expects {
+		buf := bytes.NewBufferString("")
+		root.BuildHTMLWithOptions(buf, expect.opts)
+		if buf.String() != expect.expected {
+			t.Log("expected:\n", expect.expected, "actual:\n", buf.String())
+			t.Fail()
+		}
+	}
+}
+
+func TestScanRawAttrs(t *testing.T) {
+	name, formats := scanRawAttrs([]byte(`<DIV ID=a  class = 'b c' title="x>y" checked/>`))
+	if name != "div" {
+		t.Error("unexpected", name)
+	}
+
+	expected := map[string]Attr{
+		"id":      {Key: "id", Value: "a", Quote: AttrQuoteNone, HasValue: true},
+		"class":   {Key: "class", Value: "b c", Quote: AttrQuoteSingle, HasValue: true},
+		"title":   {Key: "title", Value: "x>y", Quote: AttrQuoteDouble, HasValue: true},
+		"checked": {Key: "checked"},
+	}
+	if len(formats) != len(expected) {
+		t.Error("unexpected", formats)
+	}
+	for key, format := range expected {
+		if formats[key] != format {
+			t.Error("unexpected", key, formats[key])
+		}
+	}
+}
+
+func TestRecordAttrFormats_entity(t *testing.T) {
+	conv := NewConverter()
+	root, err := conv.Parse(strings.NewReader(`<p title='it&#39;s' class=a></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := root.GetElementsByTagName("p")[0]
+	if attr := p.GetAttr("title"); attr.Quote != AttrQuoteSingle || !attr.HasValue {
+		t.Error("unexpected", attr)
+	}
+	if attr := p.GetAttr("class"); attr.Quote != AttrQuoteNone || !attr.HasValue {
+		t.Error("unexpected", attr)
+	}
+}
@@ -0,0 +1,204 @@
+package html2html
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var _ TokenConsumer = &autoLinkConsumer{}
+
+// DefaultAutoLinkExcludeTags の中にあるテキストはリンクにしない
+var DefaultAutoLinkExcludeTags = []string{"a", "code", "pre", "script", "style", "textarea", "title"}
+
+var (
+	autoLinkURLPattern     = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+	autoLinkEmailPattern   = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9\-]+(?:\.[A-Za-z0-9\-]+)*\.[A-Za-z]{2,}`)
+	autoLinkMentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])(@[A-Za-z0-9_]{1,30})`)
+	autoLinkHashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#/])(#[\p{L}\p{N}_]*[\p{L}_][\p{L}\p{N}_]*)`)
+)
+
+// AutoLinkMatch はテキスト中のリンクにする範囲
+type AutoLinkMatch struct {
+	Start int
+	End   int
+	Href  string
+}
+
+// AutoLinkMatcher はテキストからリンクにする範囲を探す
+type AutoLinkMatcher interface {
+	FindAll(text string) []AutoLinkMatch
+}
+
+type AutoLinkMatcherFunc func(text string) []AutoLinkMatch
+
+func (f AutoLinkMatcherFunc) FindAll(text string) []AutoLinkMatch {
+	return f(text)
+}
+
+// AutoLinker は TextToken を文字列と <a> に分割する
+type AutoLinker struct {
+	Matchers    []AutoLinkMatcher
+	ExcludeTags []string
+	// ModifyLink が設定されていれば生成した <a> を渡す。rel や class を付けるのに使う
+	ModifyLink func(tag Tag)
+}
+
+func NewAutoLinker(matchers ...AutoLinkMatcher) *AutoLinker {
+	return &AutoLinker{
+		Matchers:    matchers,
+		ExcludeTags: DefaultAutoLinkExcludeTags,
+	}
+}
+
+// NewAutoLinkConsumer は TextToken を AutoLinker で分割するTokenConsumerを返す。html.TextToken に設定して使う
+func NewAutoLinkConsumer(conv Converter, linker *AutoLinker) TokenConsumer {
+	return &autoLinkConsumer{conv: conv, linker: linker}
+}
+
+type autoLinkConsumer struct {
+	conv   Converter
+	linker *AutoLinker
+}
+
+func (consumer *autoLinkConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	if token.Type != html.TextToken {
+		return consumer.conv.DefaultConsumer().ConsumeToken(parent, tokenizer, token)
+	}
+
+	if consumer.linker.IsExcluded(parent) {
+		parent.AddChildTokens(CreateTextToken(token.Data))
+	} else {
+		parent.AddChildTokens(consumer.linker.Link(token.Data)...)
+	}
+
+	tokenizer.Next()
+	return tokenizer.Token(), nil
+}
+
+// IsExcluded は parent の中のテキストをリンクにしてはいけないかを返す
+func (linker *AutoLinker) IsExcluded(parent Tag) bool {
+	for _, tagName := range linker.ExcludeTags {
+		if parent.Name() == tagName || parent.FindAncestor(tagName) != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Link は text をリンクにする部分とそれ以外に分割する
+func (linker *AutoLinker) Link(text string) []Token {
+	var matches []AutoLinkMatch
+	for _, matcher := range linker.Matchers {
+		matches = append(matches, matcher.FindAll(text)...)
+	}
+	if len(matches) == 0 {
+		return []Token{CreateTextToken(text)}
+	}
+
+	// 先に始まるもの、同じ位置なら長いものを優先し、重なるものは捨てる
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Start != matches[j].Start {
+			return matches[i].Start < matches[j].Start
+		}
+		return matches[i].End > matches[j].End
+	})
+
+	var tokens []Token
+	pos := 0
+	for _, m := range matches {
+		if m.Start < pos || m.Start >= m.End {
+			continue
+		}
+		if pos < m.Start {
+			tokens = append(tokens, CreateTextToken(text[pos:m.Start]))
+		}
+
+		a := CreateElement("a")
+		a.AddAttr("href", m.Href)
+		a.AddText(text[m.Start:m.End])
+		if linker.ModifyLink != nil {
+			linker.ModifyLink(a)
+		}
+		tokens = append(tokens, a)
+
+		pos = m.End
+	}
+	if pos < len(text) {
+		tokens = append(tokens, CreateTextToken(text[pos:]))
+	}
+
+	return tokens
+}
+
+// NewURLMatcher は http(s):// と www. から始まるURLを探すAutoLinkMatcherを返す
+func NewURLMatcher() AutoLinkMatcher {
+	return AutoLinkMatcherFunc(func(text string) []AutoLinkMatch {
+		var matches []AutoLinkMatch
+		for _, loc := range autoLinkURLPattern.FindAllStringIndex(text, -1) {
+			end := loc[0] + trimURLSuffix(text[loc[0]:loc[1]])
+			href := text[loc[0]:end]
+			if strings.HasPrefix(strings.ToLower(href), "www.") {
+				href = "http://" + href
+			}
+			matches = append(matches, AutoLinkMatch{Start: loc[0], End: end, Href: href})
+		}
+		return matches
+	})
+}
+
+// NewEmailMatcher はメールアドレスを探して mailto: のリンクにするAutoLinkMatcherを返す
+func NewEmailMatcher() AutoLinkMatcher {
+	return AutoLinkMatcherFunc(func(text string) []AutoLinkMatch {
+		var matches []AutoLinkMatch
+		for _, loc := range autoLinkEmailPattern.FindAllStringIndex(text, -1) {
+			matches = append(matches, AutoLinkMatch{Start: loc[0], End: loc[1], Href: "mailto:" + text[loc[0]:loc[1]]})
+		}
+		return matches
+	})
+}
+
+// NewMentionMatcher は @user を探すAutoLinkMatcherを返す。href には @ を除いた名前が渡される
+func NewMentionMatcher(href func(name string) string) AutoLinkMatcher {
+	return newPrefixedMatcher(autoLinkMentionPattern, href)
+}
+
+// NewHashtagMatcher は #tag を探すAutoLinkMatcherを返す。href には # を除いたタグが渡される
+func NewHashtagMatcher(href func(tag string) string) AutoLinkMatcher {
+	return newPrefixedMatcher(autoLinkHashtagPattern, href)
+}
+
+func newPrefixedMatcher(pattern *regexp.Regexp, href func(name string) string) AutoLinkMatcher {
+	return AutoLinkMatcherFunc(func(text string) []AutoLinkMatch {
+		var matches []AutoLinkMatch
+		for _, loc := range pattern.FindAllStringSubmatchIndex(text, -1) {
+			start, end := loc[2], loc[3]
+			name := text[start+1 : end]
+			matches = append(matches, AutoLinkMatch{Start: start, End: end, Href: href(url.PathEscape(name))})
+		}
+		return matches
+	})
+}
+
+// trimURLSuffix は文末の句読点や対応の取れていない閉じ括弧をURLから除いた長さを返す
+func trimURLSuffix(s string) int {
+	end := len(s)
+	for end > 0 {
+		c := s[end-1]
+		if strings.IndexByte(".,:;!?'\"", c) >= 0 {
+			end--
+			continue
+		}
+		if c == ')' && strings.Count(s[:end], "(") < strings.Count(s[:end], ")") {
+			end--
+			continue
+		}
+		break
+	}
+
+	return end
+}
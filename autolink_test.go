@@ -0,0 +1,45 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestAutoLinkConsumer(t *testing.T) {
+	input := `<p>see https://favclip.com/about. (or www.example.com) by @vvakame #golang</p><pre>https://example.com/pre</pre><a href="/x">https://example.com/a</a><p>mail info@example.com</p>`
+
+	linker := NewAutoLinker(
+		NewURLMatcher(),
+		NewEmailMatcher(),
+		NewMentionMatcher(func(name string) string { return "/users/" + name }),
+		NewHashtagMatcher(func(tag string) string { return "/tags/" + tag }),
+	)
+
+	conv := NewConverter()
+	conv.SetTokenTypeConsumer(html.TextToken, NewAutoLinkConsumer(conv, linker))
+
+	result, err := conv.Convert(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p>see <a href="https://favclip.com/about">https://favclip.com/about</a>. (or <a href="http://www.example.com">www.example.com</a>) by <a href="/users/vvakame">@vvakame</a> <a href="/tags/golang">#golang</a></p><pre>https://example.com/pre</pre><a href="/x">https://example.com/a</a><p>mail <a href="mailto:info@example.com">info@example.com</a></p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestTrimURLSuffix(t *testing.T) {
+	for s, expected := range map[string]string{
+		"https://example.com/.":               "https://example.com/",
+		"https://example.com/)":               "https://example.com/",
+		"https://en.wikipedia.org/wiki/Go_(":  "https://en.wikipedia.org/wiki/Go_(",
+		"https://en.wikipedia.org/wiki/A_(b)": "https://en.wikipedia.org/wiki/A_(b)",
+	} {
+		if v := s[:trimURLSuffix(s)]; v != expected {
+			t.Error("unexpected", s, v)
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package html2html
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// BatchManifestName は変換済みファイルのハッシュを記録するファイル名。出力先ディレクトリに作られる
+const BatchManifestName = ".html2html-manifest.json"
+
+type BatchStatus string
+
+const (
+	BatchConverted BatchStatus = "converted"
+	BatchSkipped   BatchStatus = "skipped"
+	BatchFailed    BatchStatus = "failed"
+)
+
+// BatchOptions は ConvertDir の設定
+type BatchOptions struct {
+	InputDir  string
+	OutputDir string
+	// Workers は同時に変換するファイル数。0 なら CPU 数
+	Workers int
+	// NewConverter はファイルごとに呼ばれる。Consumer が文書ごとの状態を持つことがあるので毎回新しく作ること
+	NewConverter func() Converter
+	// Match が true を返すファイルだけを変換する。デフォルトは .html と .htm
+	Match func(path string) bool
+	// CacheKey は変換の設定が変わった時に前回の結果を使わないようにするためにハッシュに混ぜる値
+	CacheKey string
+	// Force なら変更されていないファイルも変換する
+	Force bool
+}
+
+// BatchResult は1ファイルの変換結果
+type BatchResult struct {
+	Path     string        `json:"path"`
+	Status   BatchStatus   `json:"status"`
+	Error    string        `json:"error,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Duration time.Duration `json:"duration"`
+
+	hash string
+}
+
+// BatchReport は ConvertDir の結果のまとめ
+type BatchReport struct {
+	Results   []*BatchResult `json:"results"`
+	Converted int            `json:"converted"`
+	Skipped   int            `json:"skipped"`
+	Failed    int            `json:"failed"`
+	Warnings  int            `json:"warnings"`
+}
+
+// ConvertDir は opts.InputDir 以下のファイルを並列に変換し、同じディレクトリ構造で opts.OutputDir に書き出す。
+// 前回から内容が変わっていないファイルは変換しない。個々のファイルの失敗は BatchReport に記録され、error にはならない。
+func ConvertDir(opts *BatchOptions) (*BatchReport, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	newConverter := opts.NewConverter
+	if newConverter == nil {
+		newConverter = NewConverter
+	}
+	match := opts.Match
+	if match == nil {
+		match = func(path string) bool {
+			ext := strings.ToLower(filepath.Ext(path))
+			return ext == ".html" || ext == ".htm"
+		}
+	}
+
+	var paths []string
+	err := filepath.Walk(opts.InputDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !match(path) {
+			return nil
+		}
+		rel, err := filepath.Rel(opts.InputDir, path)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := readBatchManifest(opts.OutputDir)
+	if err != nil {
+		return nil, err
+	}
+
+	// manifest は結果を集めながら更新するので、前回のハッシュは先に取り出しておく
+	prevHashes := make([]string, len(paths))
+	for idx, rel := range paths {
+		prevHashes[idx] = manifest[rel]
+	}
+
+	idxCh := make(chan int)
+	resultCh := make(chan *BatchResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range idxCh {
+				resultCh <- convertBatchFile(newConverter, opts, paths[idx], prevHashes[idx])
+			}
+		}()
+	}
+	go func() {
+		for idx := range paths {
+			idxCh <- idx
+		}
+		close(idxCh)
+		wg.Wait()
+		close(resultCh)
+	}()
+
+	report := &BatchReport{}
+	for result := range resultCh {
+		report.Results = append(report.Results, result)
+		switch result.Status {
+		case BatchConverted:
+			report.Converted++
+			manifest[result.Path] = result.hash
+		case BatchSkipped:
+			report.Skipped++
+		case BatchFailed:
+			report.Failed++
+			delete(manifest, result.Path)
+		}
+		report.Warnings += len(result.Warnings)
+	}
+	sort.Slice(report.Results, func(i, j int) bool {
+		return report.Results[i].Path < report.Results[j].Path
+	})
+
+	if err := writeBatchManifest(opts.OutputDir, manifest); err != nil {
+		return report, err
+	}
+
+	return report, nil
+}
+
+func convertBatchFile(newConverter func() Converter, opts *BatchOptions, rel string, prevHash string) *BatchResult {
+	start := time.Now()
+	result := &BatchResult{Path: rel}
+	defer func() {
+		result.Duration = time.Since(start)
+	}()
+
+	fail := func(err error) *BatchResult {
+		result.Status = BatchFailed
+		result.Error = err.Error()
+		return result
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join(opts.InputDir, rel))
+	if err != nil {
+		return fail(err)
+	}
+
+	h := sha256.New()
+	io.WriteString(h, opts.CacheKey)
+	h.Write([]byte{0})
+	h.Write(src)
+	result.hash = hex.EncodeToString(h.Sum(nil))
+
+	outPath := filepath.Join(opts.OutputDir, rel)
+	if !opts.Force && prevHash == result.hash {
+		if _, err := os.Stat(outPath); err == nil {
+			result.Status = BatchSkipped
+			return result
+		}
+	}
+
+	if !utf8.Valid(src) {
+		result.Warnings = append(result.Warnings, "input is not valid UTF-8")
+	}
+
+	converted, err := newConverter().Convert(bytes.NewReader(src))
+	if err != nil {
+		return fail(err)
+	}
+	if converted == "" && len(bytes.TrimSpace(src)) != 0 {
+		result.Warnings = append(result.Warnings, "output is empty")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fail(err)
+	}
+	if err := ioutil.WriteFile(outPath, []byte(converted), 0644); err != nil {
+		return fail(err)
+	}
+
+	result.Status = BatchConverted
+	return result
+}
+
+// WriteSummary は人が読むための結果のまとめを書き出す
+func (report *BatchReport) WriteSummary(w io.Writer) error {
+	buf := bytes.NewBufferString("")
+	for _, result := range report.Results {
+		switch {
+		case result.Status == BatchFailed:
+			fmt.Fprintf(buf, "FAIL %s: %s\n", result.Path, result.Error)
+		case len(result.Warnings) != 0:
+			for _, warning := range result.Warnings {
+				fmt.Fprintf(buf, "WARN %s: %s\n", result.Path, warning)
+			}
+		}
+	}
+	fmt.Fprintf(buf, "%d converted, %d skipped, %d failed, %d warnings\n", report.Converted, report.Skipped, report.Failed, report.Warnings)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func readBatchManifest(outputDir string) (map[string]string, error) {
+	manifest := make(map[string]string)
+
+	b, err := ioutil.ReadFile(filepath.Join(outputDir, BatchManifestName))
+	if os.IsNotExist(err) {
+		return manifest, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &manifest); err != nil {
+		return nil, fmt.Errorf("%s: %s", BatchManifestName, err.Error())
+	}
+
+	return manifest, nil
+}
+
+func writeBatchManifest(outputDir string, manifest map[string]string) error {
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filepath.Join(outputDir, BatchManifestName), b, 0644)
+}
@@ -0,0 +1,79 @@
+package html2html
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestConvertDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html2html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	inputDir := filepath.Join(dir, "in")
+	outputDir := filepath.Join(dir, "out")
+	for path, content := range map[string]string{
+		"index.html":          `<a href=/>top</a>`,
+		"blog/2016/post.html": `<p>Hi!<i>`,
+		"blog/broken.html":    `<p>Hi!</b>`,
+		"style.css":           `p{}`,
+	} {
+		path = filepath.Join(inputDir, path)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	opts := &BatchOptions{
+		InputDir:  inputDir,
+		OutputDir: outputDir,
+		Workers:   2,
+	}
+	report, err := ConvertDir(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Converted != 1 || report.Failed != 2 || report.Skipped != 0 {
+		t.Fatal("unexpected", report.Converted, report.Failed, report.Skipped)
+	}
+	if v := report.Results[0]; v.Path != filepath.Join("blog", "2016", "post.html") || v.Status != BatchFailed {
+		t.Error("unexpected", v)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(outputDir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := string(b); v != `<a href="/">top</a>` {
+		t.Error("unexpected", v)
+	}
+
+	opts.NewConverter = func() Converter {
+		conv := NewConverter()
+		conv.SetRaiseErrorOnInvalidEndTag(false)
+		return conv
+	}
+	report, err = ConvertDir(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Converted != 2 || report.Failed != 0 || report.Skipped != 1 {
+		t.Fatal("unexpected", report.Converted, report.Failed, report.Skipped)
+	}
+
+	buf := bytes.NewBufferString("")
+	if err := report.WriteSummary(buf); err != nil {
+		t.Fatal(err)
+	}
+	if v := buf.String(); v != "2 converted, 1 skipped, 0 failed, 0 warnings\n" {
+		t.Error("unexpected", v)
+	}
+}
@@ -0,0 +1,145 @@
+package html2html
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+)
+
+var _ Serializer = &Canonicalizer{}
+
+// Canonicalizer は同じ内容の木が必ず同じ文字列になる Serializer。
+// 名前は小文字に、属性は名前順に並べて全て引用符で囲み、文字列と属性値は最小限のエスケープに揃え、
+// 表示に影響しない空白とコメントを取り除く。出力はハッシュを取るためのもので、見た目は保たない。
+type Canonicalizer struct {
+	// SortClasses は class 属性の値を並べ替える
+	SortClasses  bool
+	KeepComments bool
+}
+
+func NewCanonicalizer() *Canonicalizer {
+	return &Canonicalizer{}
+}
+
+func (canonicalizer *Canonicalizer) Serialize(buf *bytes.Buffer, token Token) error {
+	if token.Type() == TypeTagToken && token.Tag().IsDocumentRoot() {
+		canonicalizer.children(buf, token.Tag())
+	} else {
+		canonicalizer.write(buf, minifyNode{token: token, text: collapseSpace(textOf(token))})
+	}
+
+	return nil
+}
+
+// Fingerprint は token を正規化した文字列の SHA-256 を16進数で返す
+func (canonicalizer *Canonicalizer) Fingerprint(token Token) string {
+	buf := bytes.NewBufferString("")
+	canonicalizer.Serialize(buf, token)
+
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:])
+}
+
+// Fingerprint は NewCanonicalizer で tag を正規化した文字列の SHA-256 を16進数で返す。
+// 属性の順番や引用符、実体参照の書き方、空白の違いでは変わらない。
+func Fingerprint(tag Tag) string {
+	return NewCanonicalizer().Fingerprint(tag)
+}
+
+func (canonicalizer *Canonicalizer) children(buf *bytes.Buffer, tag Tag) {
+	for _, node := range collapseChildren(tag, canonicalizer.KeepComments) {
+		canonicalizer.write(buf, node)
+	}
+}
+
+func (canonicalizer *Canonicalizer) write(buf *bytes.Buffer, node minifyNode) {
+	switch node.token.Type() {
+	case TypeDoctypeToken:
+		buf.WriteString("<!DOCTYPE ")
+		buf.WriteString(strings.ToLower(strings.Join(strings.Fields(node.token.TextToken().Text()), " ")))
+		buf.WriteString(">")
+		return
+	case TypeTextToken:
+		buf.WriteString(escapeXML(node.text, false, nil))
+		return
+	case TypeCommentToken:
+		buf.WriteString("<!--")
+		buf.WriteString(node.token.TextToken().Text())
+		buf.WriteString("-->")
+		return
+	}
+
+	tag := node.token.Tag()
+	name := strings.ToLower(tag.Name())
+	buf.WriteString("<")
+	buf.WriteString(name)
+	for _, attr := range canonicalizer.attrs(tag) {
+		buf.WriteString(" ")
+		buf.WriteString(attr.Key)
+		buf.WriteString(`="`)
+		buf.WriteString(escapeXML(attr.Value, true, nil))
+		buf.WriteString(`"`)
+	}
+	buf.WriteString(">")
+
+	if containsString(VoidElements, name) {
+		return
+	}
+	switch {
+	case containsString(WhitespaceSensitiveElements, name):
+		for _, child := range tag.Tokens() {
+			if child.Type() == TypeTextToken {
+				if name == "script" || name == "style" {
+					buf.WriteString(child.TextToken().Text())
+				} else {
+					buf.WriteString(escapeXML(child.TextToken().Text(), false, nil))
+				}
+			} else {
+				canonicalizer.write(buf, minifyNode{token: child})
+			}
+		}
+	case !tag.IsSelfClosing():
+		canonicalizer.children(buf, tag)
+	}
+	buf.WriteString("</")
+	buf.WriteString(name)
+	buf.WriteString(">")
+}
+
+// attrs は名前を小文字にして名前順に並べた属性を返す。同じ名前の属性は最初のものだけを使う
+func (canonicalizer *Canonicalizer) attrs(tag Tag) []*Attr {
+	var attrs []*Attr
+	seen := make(map[string]bool)
+	for _, attr := range tag.Attrs() {
+		key := strings.ToLower(attr.Key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		value := attr.Value
+		if key == "class" {
+			classes := strings.Fields(value)
+			if canonicalizer.SortClasses {
+				sort.Strings(classes)
+			}
+			value = strings.Join(classes, " ")
+		}
+		attrs = append(attrs, &Attr{Key: key, Value: value})
+	}
+	sort.SliceStable(attrs, func(i, j int) bool {
+		return attrs[i].Key < attrs[j].Key
+	})
+
+	return attrs
+}
+
+func textOf(token Token) string {
+	if token.Type() == TypeTagToken {
+		return ""
+	}
+
+	return token.TextToken().Text()
+}
@@ -0,0 +1,67 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCanonicalizer(t *testing.T) {
+	html := `<DIV id=main   class="b  a"><p>Caf&eacute; &amp;
+  <b>bar</b></p><!-- comment --><br/><img alt='"x"' src=a.png></DIV>`
+
+	conv := NewConverter()
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBufferString("")
+	if err := NewCanonicalizer().Serialize(buf, root); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<div class="b a" id="main"><p>Café &amp; <b>bar</b></p><br><img alt="&quot;x&quot;" src="a.png"></div>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+
+	buf.Reset()
+	canonicalizer := NewCanonicalizer()
+	canonicalizer.SortClasses = true
+	if err := canonicalizer.Serialize(buf, root); err != nil {
+		t.Fatal(err)
+	}
+	expected = `<div class="a b" id="main"><p>Café &amp; <b>bar</b></p><br><img alt="&quot;x&quot;" src="a.png"></div>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	parse := func(html string) Tag {
+		root, err := NewConverter().Parse(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	a := Fingerprint(parse(`<div class="x" id="a"><p>Caf&eacute;  &amp; tea</p></div>`))
+	b := Fingerprint(parse(`<div  id='a' class=x>
+  <p>Café &#38;
+tea</p>
+</div>`))
+	if a != b {
+		t.Error("unexpected", a, b)
+	}
+	if len(a) != 64 {
+		t.Error("unexpected", a)
+	}
+
+	c := Fingerprint(parse(`<div class="x" id="a"><p>Café &amp; coffee</p></div>`))
+	if a == c {
+		t.Error("unexpected", a, c)
+	}
+}
@@ -0,0 +1,147 @@
+package html2html
+
+import (
+	"fmt"
+
+	"golang.org/x/net/html"
+)
+
+var _ TokenConsumer = &tokenConsumerChain{}
+var _ TagAttrsConsumer = &tagAttrsConsumerChain{}
+
+// TokenHandler は TokenConsumer のチェインの1段。
+// next を呼べば後ろの handler に処理を任せ、呼ばなければ自分で処理する(置き換え、読み捨て)。
+// next が parent に追加したTokenは、呼ぶ前の len(parent.Tokens()) 以降を見れば後から書き換えられる。
+type TokenHandler interface {
+	HandleToken(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error)
+}
+
+type TokenHandlerFunc func(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error)
+
+func (f TokenHandlerFunc) HandleToken(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error) {
+	return f(parent, tokenizer, token, next)
+}
+
+// TagAttrsHandler は TagAttrsConsumer のチェインの1段。
+// next を呼んだ後に tag の属性を書き換えることも、next を呼ばずに自分で属性を追加することもできる。
+type TagAttrsHandler interface {
+	HandleAttrs(tag Tag, token html.Token, next TagAttrsConsumer) error
+}
+
+type TagAttrsHandlerFunc func(tag Tag, token html.Token, next TagAttrsConsumer) error
+
+func (f TagAttrsHandlerFunc) HandleAttrs(tag Tag, token html.Token, next TagAttrsConsumer) error {
+	return f(tag, token, next)
+}
+
+// ChainTokenConsumer は handlers を先頭から順に通り、最後に last で処理するTokenConsumerを返す
+func ChainTokenConsumer(last TokenConsumer, handlers ...TokenHandler) TokenConsumer {
+	consumer := last
+	for i := len(handlers) - 1; i >= 0; i-- {
+		consumer = &tokenConsumerChain{handler: handlers[i], next: consumer}
+	}
+
+	return consumer
+}
+
+type tokenConsumerChain struct {
+	handler TokenHandler
+	next    TokenConsumer
+}
+
+func (chain *tokenConsumerChain) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	return chain.handler.HandleToken(parent, tokenizer, token, chain.next)
+}
+
+// ChainTagAttrsConsumer は handlers を先頭から順に通り、最後に last で処理するTagAttrsConsumerを返す
+func ChainTagAttrsConsumer(last TagAttrsConsumer, handlers ...TagAttrsHandler) TagAttrsConsumer {
+	consumer := last
+	for i := len(handlers) - 1; i >= 0; i-- {
+		consumer = &tagAttrsConsumerChain{handler: handlers[i], next: consumer}
+	}
+
+	return consumer
+}
+
+type tagAttrsConsumerChain struct {
+	handler TagAttrsHandler
+	next    TagAttrsConsumer
+}
+
+func (chain *tagAttrsConsumerChain) ConsumeAttrs(tag Tag, token html.Token) error {
+	return chain.handler.HandleAttrs(tag, token, chain.next)
+}
+
+// UseTagName は tagName に設定されているTokenConsumerの前に handlers を積む。
+// 何も設定されていなければ DefaultConsumer の処理が最後に実行される。
+func UseTagName(conv Converter, tagName string, handlers ...TokenHandler) error {
+	last := conv.ConsumerByTagName(tagName)
+	if last == nil {
+		defaultConsumer, err := defaultConsumerOf(conv)
+		if err != nil {
+			return err
+		}
+		last = consumerFunc(defaultConsumer.ConsumeTokenImpl)
+	}
+
+	conv.SetTagNameConsumer(tagName, ChainTokenConsumer(last, handlers...))
+	return nil
+}
+
+// UseTokenType は tokenType に設定されているTokenConsumerの前に handlers を積む。
+// 何も設定されていなければ要素名ごとのTokenConsumerが最後に実行される。
+func UseTokenType(conv Converter, tokenType html.TokenType, handlers ...TokenHandler) error {
+	last := conv.ConsumerByTokenType(tokenType)
+	if last == nil {
+		defaultConsumer, err := defaultConsumerOf(conv)
+		if err != nil {
+			return err
+		}
+		last = consumerFunc(defaultConsumer.ConsumeTokenByTagName)
+	}
+
+	conv.SetTokenTypeConsumer(tokenType, ChainTokenConsumer(last, handlers...))
+	return nil
+}
+
+// UseTagAttrs は DefaultConsumer に設定されているTagAttrsConsumerの前に handlers を積む
+func UseTagAttrs(conv Converter, handlers ...TagAttrsHandler) error {
+	defaultConsumer, err := defaultConsumerOf(conv)
+	if err != nil {
+		return err
+	}
+
+	last := defaultConsumer.TagAttrsConsumer()
+	if last == nil {
+		last = &copyAttrsConsumer{}
+	}
+
+	defaultConsumer.SetTagAttrsConsumer(ChainTagAttrsConsumer(last, handlers...))
+	return nil
+}
+
+type consumerFunc func(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error)
+
+func (f consumerFunc) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	return f(parent, tokenizer, token)
+}
+
+// copyAttrsConsumer は DefaultConsumer と同じく全ての属性をそのまま追加する
+type copyAttrsConsumer struct{}
+
+func (consumer *copyAttrsConsumer) ConsumeAttrs(tag Tag, token html.Token) error {
+	for _, attr := range token.Attr {
+		tag.AddAttr(attr.Key, attr.Val)
+	}
+
+	return nil
+}
+
+func defaultConsumerOf(conv Converter) (*DefaultConsumer, error) {
+	defaultConsumer, ok := conv.DefaultConsumer().(*DefaultConsumer)
+	if !ok {
+		return nil, fmt.Errorf("unexpected default consumer: %T", conv.DefaultConsumer())
+	}
+
+	return defaultConsumer, nil
+}
@@ -0,0 +1,101 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestUseTagName(t *testing.T) {
+	input := `<p><a href="/a" class="btn">a</a><a href="/b" rel="sponsored">b</a></p>`
+
+	conv := NewConverter()
+	conv.SetRaiseErrorOnInvalidEndTag(false)
+
+	// 後から書き換える
+	rewriteHref := TokenHandlerFunc(func(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error) {
+		before := len(parent.Tokens())
+		token, err := next.ConsumeToken(parent, tokenizer, token)
+		for _, added := range parent.Tokens()[before:] {
+			if added.Type() == TypeTagToken {
+				if attr := added.Tag().GetAttr("href"); attr != nil {
+					attr.Value = "https://example.com" + attr.Value
+				}
+			}
+		}
+		return token, err
+	})
+	// 読み捨てる
+	dropSponsored := TokenHandlerFunc(func(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error) {
+		for _, attr := range token.Attr {
+			if attr.Key == "rel" && attr.Val == "sponsored" {
+				return NewVacuumConsumer(conv).ConsumeToken(parent, tokenizer, token)
+			}
+		}
+		return next.ConsumeToken(parent, tokenizer, token)
+	})
+	if err := UseTagName(conv, "a", rewriteHref, dropSponsored); err != nil {
+		t.Fatal(err)
+	}
+
+	renameClass := TagAttrsHandlerFunc(func(tag Tag, token html.Token, next TagAttrsConsumer) error {
+		if err := next.ConsumeAttrs(tag, token); err != nil {
+			return err
+		}
+		if attr := tag.GetAttr("class"); attr != nil {
+			attr.Value = strings.Replace(attr.Value, "btn", "button", -1)
+		}
+		return nil
+	})
+	if err := UseTagAttrs(conv, renameClass); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := conv.Convert(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p><a href="https://example.com/a" class="button">a</a></p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestUseTokenType(t *testing.T) {
+	input := `<p>hello <b>world</b></p><!-- note -->`
+
+	conv := NewConverter()
+	conv.SetTagNameConsumer("b", NewRenameConsumer(conv, "strong"))
+
+	upper := TokenHandlerFunc(func(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error) {
+		token.Data = strings.ToUpper(token.Data)
+		return next.ConsumeToken(parent, tokenizer, token)
+	})
+	skipComment := TokenHandlerFunc(func(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error) {
+		tokenizer.Next()
+		return tokenizer.Token(), nil
+	})
+	if err := UseTokenType(conv, html.TextToken, upper); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseTokenType(conv, html.CommentToken, skipComment); err != nil {
+		t.Fatal(err)
+	}
+	if err := UseTokenType(conv, html.StartTagToken, TokenHandlerFunc(func(parent Tag, tokenizer *html.Tokenizer, token html.Token, next TokenConsumer) (html.Token, error) {
+		return next.ConsumeToken(parent, tokenizer, token)
+	})); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := conv.Convert(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p>HELLO <strong>WORLD</strong></p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
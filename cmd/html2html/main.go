@@ -0,0 +1,243 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/favclip/html2html"
+)
+
+const usage = `usage: html2html [flags] [file ...]
+
+Converts each file (or stdin if no file is given) and writes the result to
+stdout, or into the directory given by -o. A directory is converted
+recursively into -o, keeping the directory layout and skipping files that
+have not changed since the last run.
+
+flags:
+`
+
+// formats は -format で選べる出力の形式
+var formats = map[string]func() html2html.Serializer{
+	"html": func() html2html.Serializer {
+		return nil
+	},
+	"pretty": func() html2html.Serializer {
+		return html2html.NewFormatter()
+	},
+	"minify": func() html2html.Serializer {
+		return html2html.NewMinifier()
+	},
+	"xhtml": func() html2html.Serializer {
+		return &html2html.BuildOptions{XHTML: true}
+	},
+	"canonical": func() html2html.Serializer {
+		return html2html.NewCanonicalizer()
+	},
+	"json": func() html2html.Serializer {
+		return &html2html.JSONASTSerializer{}
+	},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("html2html", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "", "output directory (default: stdout)")
+	preset := flags.String("preset", "default", "converter preset: "+strings.Join(html2html.DefaultPresets.Names(), ", "))
+	configFile := flags.String("config", "", "JSON or YAML converter config file, used instead of -preset")
+	format := flags.String("format", "html", "output format: "+strings.Join(formatNames(), ", "))
+	lenient := flags.Bool("lenient", false, "interpolate missing or invalid end tags instead of failing")
+	workers := flags.Int("j", runtime.NumCPU(), "number of files converted in parallel in directory mode")
+	force := flags.Bool("force", false, "convert unchanged files in directory mode")
+	reportFile := flags.String("report", "", "write a JSON report of directory mode to this file")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var cfg *html2html.Config
+	var err error
+	if *configFile != "" {
+		cfg, err = html2html.LoadConfigFile(*configFile)
+		if err == nil {
+			cfg, err = html2html.DefaultPresets.Resolve(cfg)
+		}
+	} else {
+		cfg, err = html2html.DefaultPresets.Lookup(*preset)
+	}
+	if err == nil {
+		// 設定の間違いは変換を始める前に知らせる
+		_, err = cfg.NewConverter()
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "html2html: %s\n", err.Error())
+		return 2
+	}
+	newConverter := func() html2html.Converter {
+		conv, _ := cfg.NewConverter()
+		return conv
+	}
+	b, _ := json.Marshal(cfg)
+	cacheKey := string(b)
+	newSerializer, ok := formats[*format]
+	if !ok {
+		fmt.Fprintf(stderr, "html2html: unknown format %q\n", *format)
+		return 2
+	}
+	newLenientConverter := func() html2html.Converter {
+		conv := newConverter()
+		if *lenient {
+			conv.SetRaiseErrorOnInvalidEndTag(false)
+		}
+		if opts, ok := conv.(html2html.ConverterOptions); ok {
+			opts.SetSerializer(newSerializer())
+		}
+		return conv
+	}
+	convert := func(r io.Reader) (string, error) {
+		return newLenientConverter().Convert(r)
+	}
+
+	if flags.NArg() == 0 {
+		result, err := convert(stdin)
+		if err != nil {
+			fmt.Fprintln(stderr, diagnostic("<stdin>", err))
+			return 1
+		}
+		if *output != "" {
+			return writeOutput(stderr, filepath.Join(*output, "stdin.html"), result)
+		}
+		fmt.Fprint(stdout, result)
+		return 0
+	}
+
+	if *output != "" {
+		// 別の入力が同じ場所に書き出されて上書きされないようにする
+		targets := make(map[string]string)
+		for _, fileName := range flags.Args() {
+			target := filepath.Join(*output, filepath.Base(fileName))
+			if other, ok := targets[target]; ok {
+				fmt.Fprintf(stderr, "html2html: %s and %s would both be written to %s\n", other, fileName, target)
+				return 2
+			}
+			targets[target] = fileName
+		}
+	}
+
+	exitCode := 0
+	for _, fileName := range flags.Args() {
+		if info, err := os.Stat(fileName); err == nil && info.IsDir() {
+			if *output == "" {
+				fmt.Fprintf(stderr, "html2html: %s is a directory, -o is required\n", fileName)
+				return 2
+			}
+
+			report, err := html2html.ConvertDir(&html2html.BatchOptions{
+				InputDir:     fileName,
+				OutputDir:    filepath.Join(*output, filepath.Base(fileName)),
+				Workers:      *workers,
+				NewConverter: newLenientConverter,
+				CacheKey:     fmt.Sprintf("%s:%s:%t", cacheKey, *format, *lenient),
+				Force:        *force,
+			})
+			if err != nil {
+				fmt.Fprintf(stderr, "html2html: %s\n", err.Error())
+				return 1
+			}
+			report.WriteSummary(stderr)
+			if *reportFile != "" {
+				if code := writeReport(stderr, *reportFile, report); code != 0 {
+					exitCode = code
+				}
+			}
+			if report.Failed != 0 {
+				exitCode = 1
+			}
+			continue
+		}
+
+		result, err := convertFile(fileName, convert)
+		if err != nil {
+			fmt.Fprintln(stderr, diagnostic(fileName, err))
+			exitCode = 1
+			continue
+		}
+
+		if *output == "" {
+			fmt.Fprint(stdout, result)
+		} else if code := writeOutput(stderr, filepath.Join(*output, filepath.Base(fileName)), result); code != 0 {
+			exitCode = code
+		}
+	}
+
+	return exitCode
+}
+
+func convertFile(fileName string, convert func(r io.Reader) (string, error)) (string, error) {
+	f, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	return convert(f)
+}
+
+func writeOutput(stderr io.Writer, fileName string, result string) int {
+	if err := os.MkdirAll(filepath.Dir(fileName), 0755); err != nil {
+		fmt.Fprintf(stderr, "html2html: %s\n", err.Error())
+		return 1
+	}
+	if err := ioutil.WriteFile(fileName, []byte(result), 0644); err != nil {
+		fmt.Fprintf(stderr, "html2html: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
+
+func writeReport(stderr io.Writer, fileName string, report *html2html.BatchReport) int {
+	b, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "html2html: %s\n", err.Error())
+		return 1
+	}
+
+	return writeOutput(stderr, fileName, string(b))
+}
+
+// diagnostic は "file:line:column: message" の形式でエラーを返す
+func diagnostic(fileName string, err error) string {
+	if _, ok := err.(*html2html.ParseError); ok {
+		return fmt.Sprintf("%s:%s", fileName, err.Error())
+	} else if _, ok := err.(*os.PathError); ok {
+		return fmt.Sprintf("html2html: %s", err.Error())
+	}
+
+	return fmt.Sprintf("%s: %s", fileName, err.Error())
+}
+
+func formatNames() []string {
+	var names []string
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	stdout := bytes.NewBufferString("")
+	stderr := bytes.NewBufferString("")
+	code := run([]string{"-preset", "safe"}, strings.NewReader(`<script>alert(1)</script><a href="https://example.com/">Hi!</a>`), stdout, stderr)
+	if code != 0 {
+		t.Fatal("unexpected", code, stderr.String())
+	}
+	if v := stdout.String(); v != `<a href="https://example.com/" rel="nofollow ugc noopener" target="_blank">Hi!</a>` {
+		t.Error("unexpected", v)
+	}
+
+	stdout.Reset()
+	code = run([]string{"-preset", "comment"}, strings.NewReader(`<script>alert(1)</script><a href="https://example.com/" onclick="evil()">Hi!</a>`), stdout, stderr)
+	if code != 0 {
+		t.Fatal("unexpected", code, stderr.String())
+	}
+	if v := stdout.String(); v != `<a href="https://example.com/" rel="nofollow ugc noopener" target="_blank">Hi!</a>` {
+		t.Error("unexpected", v)
+	}
+}
+
+func TestRun_lenient(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html2html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "broken.html")
+	if err := ioutil.WriteFile(input, []byte("<p>\n<i>Hi!</b></p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stderr := bytes.NewBufferString("")
+	code := run([]string{input}, nil, bytes.NewBufferString(""), stderr)
+	if code != 1 {
+		t.Error("unexpected", code)
+	}
+	if v := stderr.String(); v != input+":2:7: unexpected end tag: b, expected: i\n" {
+		t.Error("unexpected", v)
+	}
+
+	output := filepath.Join(dir, "out")
+	code = run([]string{"-lenient", "-o", output, input}, nil, bytes.NewBufferString(""), bytes.NewBufferString(""))
+	if code != 0 {
+		t.Fatal("unexpected", code)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(output, "broken.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := string(b); v != "<p>\n<i>Hi!</i></p>" {
+		t.Error("unexpected", v)
+	}
+}
+
+func TestRun_directory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html2html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, "site")
+	if err := os.MkdirAll(filepath.Join(input, "blog"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(input, "blog", "post.html"), []byte("<p>Hi!</p>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out")
+	stderr := bytes.NewBufferString("")
+	code := run([]string{"-o", output, "-report", filepath.Join(dir, "report.json"), input}, nil, bytes.NewBufferString(""), stderr)
+	if code != 0 {
+		t.Fatal("unexpected", code, stderr.String())
+	}
+	if v := stderr.String(); v != "1 converted, 0 skipped, 0 failed, 0 warnings\n" {
+		t.Error("unexpected", v)
+	}
+	if _, err := os.Stat(filepath.Join(output, "site", "blog", "post.html")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "report.json")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRun_outputCollision(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html2html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var inputs []string
+	for _, name := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		input := filepath.Join(dir, name, "index.html")
+		if err := ioutil.WriteFile(input, []byte("<p>"+name+"</p>"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		inputs = append(inputs, input)
+	}
+
+	output := filepath.Join(dir, "out")
+	stderr := bytes.NewBufferString("")
+	code := run(append([]string{"-o", output}, inputs...), nil, bytes.NewBufferString(""), stderr)
+	if code != 2 {
+		t.Error("unexpected", code)
+	}
+	if !strings.Contains(stderr.String(), "would both be written to") {
+		t.Error("unexpected", stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(output, "index.html")); !os.IsNotExist(err) {
+		t.Error("unexpected", err)
+	}
+}
+
+func TestRun_config(t *testing.T) {
+	dir, err := ioutil.TempDir("", "html2html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	configFile := filepath.Join(dir, "config.yaml")
+	if err := ioutil.WriteFile(configFile, []byte("rename:\n  b: strong\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := bytes.NewBufferString("")
+	code := run([]string{"-config", configFile}, strings.NewReader(`<b>Hi!</b>`), stdout, bytes.NewBufferString(""))
+	if code != 0 {
+		t.Fatal("unexpected", code)
+	}
+	if v := stdout.String(); v != `<strong>Hi!</strong>` {
+		t.Error("unexpected", v)
+	}
+
+	if err := ioutil.WriteFile(configFile, []byte("rename:\n  b: [strong]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	stderr := bytes.NewBufferString("")
+	code = run([]string{"-config", configFile}, strings.NewReader(`<b>Hi!</b>`), stdout, stderr)
+	if code != 2 {
+		t.Error("unexpected", code)
+	}
+	if v := stderr.String(); !strings.HasPrefix(v, "html2html: "+configFile+":2:3: ") {
+		t.Error("unexpected", v)
+	}
+}
+
+func TestRun_format(t *testing.T) {
+	stdout := bytes.NewBufferString("")
+	code := run([]string{"-format", "pretty"}, strings.NewReader(`<ul><li>a</li><li>b</li></ul>`), stdout, bytes.NewBufferString(""))
+	if code != 0 {
+		t.Fatal("unexpected", code)
+	}
+	if v := stdout.String(); v != "<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>\n" {
+		t.Error("unexpected", v)
+	}
+}
@@ -0,0 +1,418 @@
+package html2html
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"gopkg.in/yaml.v3"
+)
+
+var _ TagAttrsConsumer = &configAttrsConsumer{}
+
+var (
+	tagNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9\-]*$`)
+	yamlErrorLinePattern = regexp.MustCompile(`line (\d+)`)
+	yamlErrorLinePrefix  = regexp.MustCompile(`^line \d+: `)
+)
+
+// Config は Converter の設定を宣言的に書いたもの。JSON と YAML のどちらでも読み込める。
+//
+//	extends: comment
+//	keep: [iframe]
+//	lenient: true
+//	drop: [script, style]
+//	unwrap: [font]
+//	rename: {b: strong, i: em}
+//	attributes:
+//	  "*": [class, title]
+//	  a: [href]
+//	urls:
+//	  base: https://example.com/
+//	  rewrite:
+//	    - {from: "http://img.example.com/", to: "https://cdn.example.com/"}
+//	links:
+//	  internal: [example.com, "*.example.com"]
+//	  rel: [nofollow]
+//	  target: _blank
+type Config struct {
+	// Extends は継承する preset の名前
+	Extends string `json:"extends,omitempty" yaml:"extends,omitempty"`
+	// Keep の要素は継承した drop, unwrap, rename の対象から外す
+	Keep []string `json:"keep,omitempty" yaml:"keep,omitempty"`
+	// Lenient が true なら閉じタグの間違いをエラーにせず補完する。nil なら継承した値か false
+	Lenient *bool `json:"lenient,omitempty" yaml:"lenient,omitempty"`
+	// Drop の要素は中身ごと取り除く
+	Drop []string `json:"drop,omitempty" yaml:"drop,omitempty"`
+	// Unwrap の要素はタグだけを取り除き、中身は残す
+	Unwrap []string `json:"unwrap,omitempty" yaml:"unwrap,omitempty"`
+	// Rename は要素名を変える
+	Rename map[string]string `json:"rename,omitempty" yaml:"rename,omitempty"`
+	// Attributes が指定されていれば、要素名(全ての要素は "*")ごとに列挙された属性だけを残す
+	Attributes map[string][]string `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	// URLs はURLを持つ属性の書き換え方
+	URLs *URLConfig `json:"urls,omitempty" yaml:"urls,omitempty"`
+	// Links が指定されていれば <a> に LinkPolicy を適用する
+	Links *LinkConfig `json:"links,omitempty" yaml:"links,omitempty"`
+}
+
+// LinkConfig は LinkPolicy の設定。Rel と Target が空なら NewLinkPolicy の値を使う
+type LinkConfig struct {
+	Internal []string `json:"internal,omitempty" yaml:"internal,omitempty"`
+	Rel      []string `json:"rel,omitempty" yaml:"rel,omitempty"`
+	Target   string   `json:"target,omitempty" yaml:"target,omitempty"`
+}
+
+// URLConfig は URLRewriter の設定
+type URLConfig struct {
+	Base    string           `json:"base,omitempty" yaml:"base,omitempty"`
+	Rewrite []URLRewriteRule `json:"rewrite,omitempty" yaml:"rewrite,omitempty"`
+}
+
+// URLRewriteRule は解決済みのURLが From で始まっていれば、その部分を To に置き換える
+type URLRewriteRule struct {
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
+}
+
+// ConfigError は設定の間違いと、その場所を持つエラー
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (err *ConfigError) Error() string {
+	if err.File != "" {
+		return fmt.Sprintf("%s:%d:%d: %s", err.File, err.Line, err.Column, err.Msg)
+	}
+	return fmt.Sprintf("%d:%d: %s", err.Line, err.Column, err.Msg)
+}
+
+// LoadConfigFile は fileName から設定を読み込む
+func LoadConfigFile(fileName string) (*Config, error) {
+	b, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := ParseConfig(b)
+	if configErr, ok := err.(*ConfigError); ok {
+		configErr.File = fileName
+	}
+
+	return cfg, err
+}
+
+// LoadConfig は r から設定を読み込む
+func LoadConfig(r io.Reader) (*Config, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseConfig(b)
+}
+
+// ParseConfig は JSON か YAML の設定を読み込んで検証する。間違いがあれば *ConfigError を返す
+func ParseConfig(b []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		line := 0
+		if m := yamlErrorLinePattern.FindStringSubmatch(err.Error()); m != nil {
+			line, _ = strconv.Atoi(m[1])
+		}
+		return nil, &ConfigError{Line: line, Msg: strings.TrimPrefix(err.Error(), "yaml: ")}
+	}
+
+	cfg := &Config{}
+	if len(doc.Content) == 0 {
+		return cfg, nil
+	}
+
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, configErrorAt(root, "config must be a mapping")
+	}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		var err error
+		switch key.Value {
+		case "extends":
+			err = decodeConfigNode(value, &cfg.Extends)
+		case "keep":
+			if err = decodeConfigNode(value, &cfg.Keep); err == nil {
+				err = validateTagNames(value)
+			}
+		case "lenient":
+			err = decodeConfigNode(value, &cfg.Lenient)
+		case "drop":
+			if err = decodeConfigNode(value, &cfg.Drop); err == nil {
+				err = validateTagNames(value)
+			}
+		case "unwrap":
+			if err = decodeConfigNode(value, &cfg.Unwrap); err == nil {
+				err = validateTagNames(value)
+			}
+		case "rename":
+			if err = decodeConfigNode(value, &cfg.Rename); err == nil {
+				err = validateTagNames(value)
+			}
+		case "attributes":
+			if err = decodeConfigNode(value, &cfg.Attributes); err == nil {
+				err = validateAttributes(value)
+			}
+		case "urls":
+			if err = decodeConfigNode(value, &cfg.URLs); err == nil {
+				err = validateURLs(value)
+			}
+		case "links":
+			if err = decodeConfigNode(value, &cfg.Links); err == nil {
+				err = validateLinks(value)
+			}
+		default:
+			err = configErrorAt(key, fmt.Sprintf("unknown key %q", key.Value))
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := validateTagRules(root); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// NewConverter は設定に従って Converter を組み立てる。Extends は DefaultPresets から探す
+func (cfg *Config) NewConverter() (Converter, error) {
+	if cfg.Extends != "" {
+		resolved, err := DefaultPresets.Resolve(cfg)
+		if err != nil {
+			return nil, err
+		}
+		cfg = resolved
+	}
+
+	conv := NewConverter()
+	conv.SetRaiseErrorOnInvalidEndTag(cfg.Lenient == nil || !*cfg.Lenient)
+
+	// drop, unwrap, rename が指定された <a> はそちらを優先する
+	if cfg.Links != nil {
+		conv.SetTagNameConsumer("a", NewLinkPolicyConsumer(conv, cfg.Links.NewLinkPolicy()))
+	}
+
+	for _, tagName := range cfg.Drop {
+		conv.SetTagNameConsumer(tagName, NewVacuumConsumer(conv))
+	}
+	for _, tagName := range cfg.Unwrap {
+		conv.SetTagNameConsumer(tagName, NewUnwrapConsumer(conv))
+	}
+	for from, to := range cfg.Rename {
+		conv.SetTagNameConsumer(from, NewRenameConsumer(conv, to))
+	}
+
+	attrsConsumer := &configAttrsConsumer{allow: cfg.Attributes}
+	if cfg.URLs != nil {
+		rewriter, err := cfg.URLs.NewURLRewriter()
+		if err != nil {
+			return nil, err
+		}
+		attrsConsumer.rewriter = rewriter
+	}
+	if attrsConsumer.allow != nil || attrsConsumer.rewriter != nil {
+		consumer, err := defaultConsumerOf(conv)
+		if err != nil {
+			return nil, err
+		}
+		consumer.SetTagAttrsConsumer(attrsConsumer)
+	}
+
+	return conv, nil
+}
+
+// NewURLRewriter は設定に従って URLRewriter を作る
+func (urlCfg *URLConfig) NewURLRewriter() (*URLRewriter, error) {
+	var base *url.URL
+	if urlCfg.Base != "" {
+		var err error
+		base, err = url.Parse(urlCfg.Base)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rules := urlCfg.Rewrite
+	return NewURLRewriter(base, func(tag Tag, attrKey string, u *url.URL) (*url.URL, error) {
+		s := u.String()
+		for _, rule := range rules {
+			if strings.HasPrefix(s, rule.From) {
+				return url.Parse(rule.To + s[len(rule.From):])
+			}
+		}
+		return u, nil
+	}), nil
+}
+
+// NewLinkPolicy は設定に従って LinkPolicy を作る
+func (linkCfg *LinkConfig) NewLinkPolicy() *LinkPolicy {
+	policy := NewLinkPolicy(linkCfg.Internal...)
+	if len(linkCfg.Rel) != 0 {
+		policy.Rel = linkCfg.Rel
+	}
+	if linkCfg.Target != "" {
+		policy.Target = linkCfg.Target
+	}
+
+	return policy
+}
+
+type configAttrsConsumer struct {
+	allow    map[string][]string
+	rewriter *URLRewriter
+}
+
+func (consumer *configAttrsConsumer) ConsumeAttrs(tag Tag, token html.Token) error {
+	for _, attr := range token.Attr {
+		if consumer.allow != nil && !containsString(consumer.allow[tag.Name()], attr.Key) && !containsString(consumer.allow["*"], attr.Key) {
+			continue
+		}
+		tag.AddAttr(attr.Key, attr.Val)
+	}
+
+	if consumer.rewriter != nil {
+		return consumer.rewriter.RewriteTag(tag)
+	}
+
+	return nil
+}
+
+func decodeConfigNode(node *yaml.Node, v interface{}) error {
+	if err := node.Decode(v); err != nil {
+		msg := err.Error()
+		if typeErr, ok := err.(*yaml.TypeError); ok && len(typeErr.Errors) != 0 {
+			msg = typeErr.Errors[0]
+		}
+		// 入れ子の値の間違いはその行の最初のノードを指す
+		at := node
+		if m := yamlErrorLinePattern.FindStringSubmatch(msg); m != nil {
+			line, _ := strconv.Atoi(m[1])
+			if found := findConfigNodeByLine(node, line); found != nil {
+				at = found
+			}
+		}
+		msg = yamlErrorLinePrefix.ReplaceAllString(strings.TrimPrefix(msg, "yaml: "), "")
+		return configErrorAt(at, msg)
+	}
+
+	return nil
+}
+
+func findConfigNodeByLine(node *yaml.Node, line int) *yaml.Node {
+	if node.Line == line {
+		return node
+	}
+	for _, child := range node.Content {
+		if found := findConfigNodeByLine(child, line); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func validateTagNames(node *yaml.Node) error {
+	for _, child := range node.Content {
+		if !tagNamePattern.MatchString(child.Value) {
+			return configErrorAt(child, fmt.Sprintf("invalid tag name %q", child.Value))
+		}
+	}
+
+	return nil
+}
+
+func validateAttributes(node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if key.Value != "*" && !tagNamePattern.MatchString(key.Value) {
+			return configErrorAt(key, fmt.Sprintf("invalid tag name %q", key.Value))
+		}
+	}
+
+	return nil
+}
+
+func validateURLs(node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		switch key.Value {
+		case "base":
+			if u, err := url.Parse(value.Value); err != nil || !u.IsAbs() {
+				return configErrorAt(value, fmt.Sprintf("base must be an absolute URL: %q", value.Value))
+			}
+		case "rewrite":
+			for _, rule := range value.Content {
+				for j := 0; j+1 < len(rule.Content); j += 2 {
+					if k := rule.Content[j]; k.Value != "from" && k.Value != "to" {
+						return configErrorAt(k, fmt.Sprintf("unknown key %q", k.Value))
+					}
+				}
+			}
+		default:
+			return configErrorAt(key, fmt.Sprintf("unknown key %q", key.Value))
+		}
+	}
+
+	return nil
+}
+
+func validateLinks(node *yaml.Node) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if key := node.Content[i]; key.Value != "internal" && key.Value != "rel" && key.Value != "target" {
+			return configErrorAt(key, fmt.Sprintf("unknown key %q", key.Value))
+		}
+	}
+
+	return nil
+}
+
+// validateTagRules は1つの要素に drop, unwrap, rename のうち2つ以上が指定されていないか確かめる
+func validateTagRules(root *yaml.Node) error {
+	seen := make(map[string]string)
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		var names []*yaml.Node
+		switch key.Value {
+		case "drop", "unwrap":
+			names = value.Content
+		case "rename":
+			for j := 0; j < len(value.Content); j += 2 {
+				names = append(names, value.Content[j])
+			}
+		default:
+			continue
+		}
+
+		for _, name := range names {
+			if prev, ok := seen[name.Value]; ok && prev != key.Value {
+				return configErrorAt(name, fmt.Sprintf("%q is already in %s", name.Value, prev))
+			}
+			seen[name.Value] = key.Value
+		}
+	}
+
+	return nil
+}
+
+func configErrorAt(node *yaml.Node, msg string) *ConfigError {
+	return &ConfigError{Line: node.Line, Column: node.Column, Msg: msg}
+}
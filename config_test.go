@@ -0,0 +1,101 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfig_NewConverter(t *testing.T) {
+	cfg, err := LoadConfig(strings.NewReader(`
+lenient: true
+drop: [script]
+unwrap: [font]
+rename: {b: strong}
+attributes:
+  "*": [class]
+  a: [href]
+  img: [src, alt]
+urls:
+  base: https://example.com/
+  rewrite:
+    - {from: "https://example.com/img/", to: "https://cdn.example.com/"}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conv, err := cfg.NewConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := `<p class="x" onclick="evil()"><font color="red"><b>Hi!<b>!</b></b></font><script>alert(1)</script><a href="/about" target="_top">about</a><img src="img/a.png" alt="a" width="10"></p><i>`
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p class="x"><strong>Hi!<strong>!</strong></strong><a href="https://example.com/about">about</a><img src="https://cdn.example.com/a.png" alt="a"></p><i></i>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestParseConfig_json(t *testing.T) {
+	cfg, err := ParseConfig([]byte(`{"drop": ["script", "style"], "rename": {"i": "em"}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Drop) != 2 || cfg.Rename["i"] != "em" {
+		t.Error("unexpected", cfg)
+	}
+}
+
+func TestConfig_links(t *testing.T) {
+	cfg, err := ParseConfig([]byte("lenient: false\nlinks:\n  internal: [example.com]\n  rel: [nofollow]\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lenient == nil || *cfg.Lenient {
+		t.Error("unexpected", cfg.Lenient)
+	}
+
+	conv, err := cfg.NewConverter()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.Convert(strings.NewReader(`<a href="https://example.com/">in</a><a href="https://example.org/">out</a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<a href="https://example.com/">in</a><a href="https://example.org/" rel="nofollow" target="_blank">out</a>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestParseConfig_errors(t *testing.T) {
+	for src, expected := range map[string]string{
+		"drop: [script]\nunknown: 1\n":              `2:1: unknown key "unknown"`,
+		"drop:\n  - script\n  - \"Bad Tag\"\n":      `3:5: invalid tag name "Bad Tag"`,
+		"lenient: yes please\n":                     "1:10: cannot unmarshal !!str `yes please` into bool",
+		"drop: [b]\nrename:\n  b: strong\n":         `3:3: "b" is already in drop`,
+		"urls:\n  base: /relative\n":                `2:9: base must be an absolute URL: "/relative"`,
+		"{\"drop\": [\"script\"],\n \"unwrap\": 1}": "2:12: cannot unmarshal !!int `1` into []string",
+		"attributes:\n  a: [href]\n  img: src\n":    "3:3: cannot unmarshal !!str `src` into []string",
+		"links:\n  rels: [nofollow]\n":              `2:3: unknown key "rels"`,
+	} {
+		_, err := ParseConfig([]byte(src))
+		if err == nil {
+			t.Error("error expected", src)
+			continue
+		}
+		if _, ok := err.(*ConfigError); !ok {
+			t.Error("unexpected error type", err)
+		}
+		if v := err.Error(); v != expected {
+			t.Error("unexpected", v)
+		}
+	}
+}
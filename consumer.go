@@ -25,9 +25,14 @@ func (consumer *DefaultConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokeni
 		return nextConsumer.ConsumeToken(parent, tokenizer, token)
 	}
 
+	return consumer.ConsumeTokenByTagName(parent, tokenizer, token)
+}
+
+// ConsumeTokenByTagName は TokenType ごとのTokenConsumerを飛ばし、要素名ごとのTokenConsumerから処理する
+func (consumer *DefaultConsumer) ConsumeTokenByTagName(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
 	switch token.Type {
 	case html.StartTagToken, html.SelfClosingTagToken:
-		nextConsumer = consumer.conv.ConsumerByTagName(token.Data)
+		nextConsumer := consumer.conv.ConsumerByTagName(token.Data)
 		if nextConsumer == nil {
 			// skip
 		} else if c, ok := nextConsumer.(*DefaultConsumer); ok {
@@ -67,19 +72,25 @@ func (consumer *DefaultConsumer) ConsumeTokenImpl(parent Tag, tokenizer *html.To
 		return token, fmt.Errorf("unknown state")
 
 	case html.DoctypeToken:
-		parent.AddChildTokens(CreateDoctypeToken(token.Data))
+		doctype := CreateDoctypeToken(token.Data)
+		consumer.recordTextSource(doctype, tokenizer, token)
+		parent.AddChildTokens(doctype)
 
 		tokenizer.Next()
 		return tokenizer.Token(), nil
 
 	case html.TextToken:
-		parent.AddChildTokens(CreateTextToken(token.Data))
+		text := CreateTextToken(token.Data)
+		consumer.recordTextSource(text, tokenizer, token)
+		parent.AddChildTokens(text)
 
 		tokenizer.Next()
 		return tokenizer.Token(), nil
 
 	case html.CommentToken:
-		parent.AddChildTokens(CreateCommentToken(token.Data))
+		comment := CreateCommentToken(token.Data)
+		consumer.recordTextSource(comment, tokenizer, token)
+		parent.AddChildTokens(comment)
 
 		tokenizer.Next()
 		return tokenizer.Token(), nil
@@ -90,6 +101,7 @@ func (consumer *DefaultConsumer) ConsumeTokenImpl(parent Tag, tokenizer *html.To
 		if err != nil {
 			return token, err
 		}
+		consumer.recordTagSource(child, tokenizer, token)
 		parent.AddChildTokens(child)
 
 		tokenizer.Next()
@@ -110,6 +122,7 @@ func (consumer *DefaultConsumer) ConsumeTokenImpl(parent Tag, tokenizer *html.To
 		if err != nil {
 			return token, err
 		}
+		consumer.recordTagSource(child, tokenizer, token)
 		parent.AddChildTokens(child)
 
 		return consumer.ConsumeElementBody(child, tokenizer, token, token.Data)
@@ -157,6 +170,10 @@ func (consumer *DefaultConsumer) ConsumeElementBody(tag Tag, tokenizer *html.Tok
 		endTagName := token.Data
 
 		if startTagName == endTagName {
+			if isLossless(consumer.conv) {
+				setEndTagSource(tag, consumer.rawToken(tokenizer))
+			}
+			consumer.recordPosition(tag, tokenizer)
 			break
 		} else if consumer.conv.RaiseErrorOnInvalidEndTag() {
 			return token, fmt.Errorf("unexpected end tag: %s, expected: %s", endTagName, startTagName)
@@ -166,6 +183,58 @@ func (consumer *DefaultConsumer) ConsumeElementBody(tag Tag, tokenizer *html.Tok
 	return tokenizer.Token(), nil
 }
 
+// recordTagSource は開始タグの属性の書き方と、Lossless なら元の文字列を tag に記録する
+func (consumer *DefaultConsumer) recordTagSource(tag Tag, tokenizer *html.Tokenizer, token html.Token) {
+	raw := consumer.rawToken(tokenizer)
+	recordAttrFormats(tag, token, raw)
+	if isLossless(consumer.conv) {
+		setTagSource(tag, raw, token)
+	}
+	consumer.recordPosition(tag, tokenizer)
+}
+
+func (consumer *DefaultConsumer) recordTextSource(textToken Token, tokenizer *html.Tokenizer, token html.Token) {
+	if isLossless(consumer.conv) {
+		setTextSource(textToken, consumer.rawToken(tokenizer), token.Data)
+	}
+	consumer.recordPosition(textToken, tokenizer)
+}
+
+// recordPosition は RecordPositions なら tokenizer の現在のTokenの位置を token に記録する。
+// 終了タグの位置は開始タグからの範囲を広げる。
+func (consumer *DefaultConsumer) recordPosition(token Token, tokenizer *html.Tokenizer) {
+	if !recordsPositions(consumer.conv) {
+		return
+	}
+	src, ok := consumer.conv.(rawSource)
+	if !ok {
+		return
+	}
+	position, ok := src.tokenRange(tokenizer)
+	if !ok {
+		return
+	}
+
+	if current, ok := SourcePosition(token); ok {
+		position.Start = current.Start
+	}
+	SetSourcePosition(token, position)
+}
+
+func (consumer *DefaultConsumer) rawToken(tokenizer *html.Tokenizer) []byte {
+	if src, ok := consumer.conv.(rawSource); ok {
+		if raw := src.rawToken(tokenizer); raw != nil {
+			return raw
+		}
+	}
+
+	return tokenizer.Raw()
+}
+
+func (consumer *DefaultConsumer) TagAttrsConsumer() TagAttrsConsumer {
+	return consumer.attrsConsumer
+}
+
 func (consumer *DefaultConsumer) SetTagAttrsConsumer(attrsConsumer TagAttrsConsumer) {
 	consumer.attrsConsumer = attrsConsumer
 }
@@ -181,9 +250,96 @@ type vacuumConsumer struct {
 
 func (consumer *vacuumConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
 	tag := CreateElement(token.Data)
+	prev := consumer.conv.ConsumerByTagName(tag.Name())
 	consumer.conv.SetTagNameConsumer(tag.Name(), nil)
 	defer func() {
-		consumer.conv.SetTagNameConsumer(tag.Name(), consumer)
+		consumer.conv.SetTagNameConsumer(tag.Name(), prev)
 	}()
 	return consumer.conv.DefaultConsumer().ConsumeToken(tag, tokenizer, token)
 }
+
+// consumeElement は token から始まる要素を、tagName に設定された TokenConsumer を一時的に外して仮のrootに読み込む。
+// 仮のrootは parent にぶら下げてあるので FindAncestor は本来の祖先まで辿れる。
+func consumeElement(conv Converter, parent Tag, tokenizer *html.Tokenizer, token html.Token) (Tag, html.Token, error) {
+	tmp := CreateDocumentRoot()
+	tmp.setParent(parent)
+
+	tagName := token.Data
+	prev := conv.ConsumerByTagName(tagName)
+	conv.SetTagNameConsumer(tagName, nil)
+	defer conv.SetTagNameConsumer(tagName, prev)
+
+	token, err := conv.DefaultConsumer().ConsumeToken(tmp, tokenizer, token)
+	return tmp, token, err
+}
+
+// NewUnwrapConsumer は渡されたTokenから始まる要素のタグだけを捨て、中身を残すTokenConsumerを返す
+func NewUnwrapConsumer(conv Converter) TokenConsumer {
+	return &unwrapConsumer{conv}
+}
+
+type unwrapConsumer struct {
+	conv Converter
+}
+
+func (consumer *unwrapConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	tagName := token.Data
+	tmp, token, err := consumeElement(consumer.conv, parent, tokenizer, token)
+	if err != nil && err != io.EOF {
+		return token, err
+	}
+
+	parent.AddChildTokens(unwrapTags(tmp, tagName)...)
+
+	return token, err
+}
+
+// unwrapTags は tag の子孫の tagName の要素を中身で置き換えて、tag の子を返す
+func unwrapTags(tag Tag, tagName string) []Token {
+	var tokens []Token
+	for _, token := range tag.Tokens() {
+		if token.Type() != TypeTagToken {
+			tokens = append(tokens, token)
+			continue
+		}
+
+		child := token.Tag()
+		childTokens := unwrapTags(child, tagName)
+		if child.Name() == tagName {
+			tokens = append(tokens, childTokens...)
+			continue
+		}
+		child.SetTokens(childTokens)
+		tokens = append(tokens, child)
+	}
+
+	return tokens
+}
+
+// NewRenameConsumer は渡されたTokenから始まる要素の名前を tagName に変えるTokenConsumerを返す
+func NewRenameConsumer(conv Converter, tagName string) TokenConsumer {
+	return &renameConsumer{conv: conv, tagName: tagName}
+}
+
+type renameConsumer struct {
+	conv    Converter
+	tagName string
+}
+
+func (consumer *renameConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	from := token.Data
+	tmp, token, err := consumeElement(consumer.conv, parent, tokenizer, token)
+	if err != nil && err != io.EOF {
+		return token, err
+	}
+
+	for _, tag := range tmp.GetElementsByTagName(from) {
+		renamed := CreateElement(consumer.tagName)
+		renamed.SetAttrs(tag.Attrs())
+		renamed.SetTokens(tag.Tokens())
+		tag.Parent().ReplateChildToken(tag, renamed)
+	}
+	parent.AddChildTokens(tmp.Tokens()...)
+
+	return token, err
+}
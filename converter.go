@@ -2,48 +2,78 @@ package html2html
 
 import (
 	"bytes"
+	"fmt"
 	"io"
+	"sync"
+	"unicode/utf8"
 
 	"golang.org/x/net/context"
 	"golang.org/x/net/html"
 )
 
 var _ Converter = &defaultConverter{}
+var _ ConverterOptions = &defaultConverter{}
 
 type defaultConverter struct {
+	// Consumer は Parse 中に Converter の設定を書き換えることがあるので Parse は同時に1つしか実行しない
+	mu sync.Mutex
+	// optsMu は以下の設定を守る。Parse 中の Consumer からも読み書きするので mu とは別にする
+	optsMu sync.RWMutex
+
 	defaultConsumer           TokenConsumer
 	raiseErrorOnInvalidEndTag bool
 
 	c                 context.Context
 	tokenTypeConsumer map[html.TokenType]TokenConsumer
 	tagConsumer       map[string]TokenConsumer
+	pipeline          *Pipeline
+	serializer        Serializer
+	lossless          bool
+	recordPositions   bool
+
+	// src は Parse 中に読んでいる元の文字列。mu で守る
+	src *sourceReader
 }
 
 func (conv *defaultConverter) DefaultConsumer() TokenConsumer {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
 	return conv.defaultConsumer
 }
 
 func (conv *defaultConverter) RaiseErrorOnInvalidEndTag() bool {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
 	return conv.raiseErrorOnInvalidEndTag
 }
 
 func (conv *defaultConverter) ConsumerByTokenType(tokenType html.TokenType) TokenConsumer {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
 	return conv.tokenTypeConsumer[tokenType]
 }
 
 func (conv *defaultConverter) ConsumerByTagName(tagName string) TokenConsumer {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
 	return conv.tagConsumer[tagName]
 }
 
 func (conv *defaultConverter) SetRaiseErrorOnInvalidEndTag(newVal bool) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
 	conv.raiseErrorOnInvalidEndTag = newVal
 }
 
 func (conv *defaultConverter) SetDefaultConsumer(consumer TokenConsumer) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
 	conv.defaultConsumer = consumer
 }
 
 func (conv *defaultConverter) SetTokenTypeConsumer(tokenType html.TokenType, consumer TokenConsumer) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
 	if conv.tokenTypeConsumer == nil {
 		conv.tokenTypeConsumer = make(map[html.TokenType]TokenConsumer)
 	}
@@ -51,14 +81,72 @@ func (conv *defaultConverter) SetTokenTypeConsumer(tokenType html.TokenType, con
 }
 
 func (conv *defaultConverter) SetTagNameConsumer(tagName string, consumer TokenConsumer) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
 	if conv.tagConsumer == nil {
 		conv.tagConsumer = make(map[string]TokenConsumer)
 	}
 	conv.tagConsumer[tagName] = consumer
 }
 
+func (conv *defaultConverter) Pipeline() *Pipeline {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
+	return conv.pipeline
+}
+
+func (conv *defaultConverter) SetPipeline(pipeline *Pipeline) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
+	conv.pipeline = pipeline
+}
+
+func (conv *defaultConverter) Serializer() Serializer {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
+	return conv.serializer
+}
+
+func (conv *defaultConverter) SetSerializer(serializer Serializer) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
+	conv.serializer = serializer
+}
+
+func (conv *defaultConverter) Lossless() bool {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
+	return conv.lossless
+}
+
+func (conv *defaultConverter) SetLossless(lossless bool) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
+	conv.lossless = lossless
+}
+
+func (conv *defaultConverter) RecordPositions() bool {
+	conv.optsMu.RLock()
+	defer conv.optsMu.RUnlock()
+	return conv.recordPositions
+}
+
+func (conv *defaultConverter) SetRecordPositions(recordPositions bool) {
+	conv.optsMu.Lock()
+	defer conv.optsMu.Unlock()
+	conv.recordPositions = recordPositions
+}
+
 func (conv *defaultConverter) Parse(r io.Reader) (Tag, error) {
-	tokenizer := html.NewTokenizer(r)
+	conv.mu.Lock()
+	defer conv.mu.Unlock()
+
+	src := newSourceReader(r)
+	tokenizer := html.NewTokenizer(src)
+	conv.src = src
+	defer func() {
+		conv.src = nil
+	}()
 
 	tokenizer.Next()
 	token := tokenizer.Token()
@@ -71,7 +159,7 @@ func (conv *defaultConverter) Parse(r io.Reader) (Tag, error) {
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return nil, err
+			return nil, src.errorAt(tokenizer, err)
 		}
 	}
 
@@ -87,7 +175,166 @@ func (conv *defaultConverter) Convert(r io.Reader) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if pipeline := conv.Pipeline(); pipeline != nil {
+		if _, err := pipeline.Run(tag); err != nil {
+			return "", err
+		}
+	}
 	buf := bytes.NewBufferString("")
-	tag.BuildHTML(buf)
+	if serializer := conv.Serializer(); serializer != nil {
+		if err := serializer.Serialize(buf, tag); err != nil {
+			return "", err
+		}
+	} else if conv.Lossless() {
+		tag.BuildHTMLWithOptions(buf, &BuildOptions{Lossless: true})
+	} else {
+		tag.BuildHTML(buf)
+	}
 	return buf.String(), nil
 }
+
+// isLossless は conv が ConverterOptions を満たし、Lossless が true かを返す
+func isLossless(conv Converter) bool {
+	opts, ok := conv.(ConverterOptions)
+	return ok && opts.Lossless()
+}
+
+// recordsPositions は conv が ConverterOptions を満たし、RecordPositions が true かを返す
+func recordsPositions(conv Converter) bool {
+	opts, ok := conv.(ConverterOptions)
+	return ok && opts.RecordPositions()
+}
+
+// rawSource は Parse 中のTokenの元の文字列を返せる Converter
+type rawSource interface {
+	rawToken(tokenizer *html.Tokenizer) []byte
+	tokenRange(tokenizer *html.Tokenizer) (SourceRange, bool)
+}
+
+// rawToken は tokenizer の現在のTokenの元の文字列を返す。
+// tokenizer.Raw は Token を呼ぶと実体参照が置き換えられてしまうので、Parse で読んだ内容から切り出す。
+func (conv *defaultConverter) rawToken(tokenizer *html.Tokenizer) []byte {
+	start, end, ok := conv.tokenOffsets(tokenizer)
+	if !ok {
+		return nil
+	}
+
+	return append([]byte(nil), conv.src.slice(start, end)...)
+}
+
+// tokenRange は tokenizer の現在のTokenの元の文字列での位置を返す
+func (conv *defaultConverter) tokenRange(tokenizer *html.Tokenizer) (SourceRange, bool) {
+	start, end, ok := conv.tokenOffsets(tokenizer)
+	if !ok {
+		return SourceRange{}, false
+	}
+
+	return SourceRange{Start: conv.src.position(start), End: conv.src.position(end)}, true
+}
+
+func (conv *defaultConverter) tokenOffsets(tokenizer *html.Tokenizer) (int, int, bool) {
+	if conv.src == nil {
+		return 0, 0, false
+	}
+
+	end := conv.src.end() - len(tokenizer.Buffered())
+	start := end - len(tokenizer.Raw())
+	if start < conv.src.offset || end > conv.src.end() {
+		return 0, 0, false
+	}
+	// Tokenは先頭から順に読むので、現在のTokenより前はもう使わない
+	conv.src.discard(start)
+
+	return start, end, true
+}
+
+// ParseError は Parse に失敗した位置を持つエラー。Line と Column は1から始まる
+type ParseError struct {
+	Offset int
+	Line   int
+	Column int
+	Err    error
+}
+
+func (err *ParseError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", err.Line, err.Column, err.Err.Error())
+}
+
+func (err *ParseError) Unwrap() error {
+	return err.Err
+}
+
+// sourceReader は読んだ内容のうち現在のToken以降だけを覚えておき、Token とエラーの位置を計算できるようにする。
+// 捨てた内容は行と列だけを数えておく
+type sourceReader struct {
+	r io.Reader
+	// buf は入力の offset バイト目以降の内容
+	buf    []byte
+	offset int
+
+	// base は offset の位置、last は最後に position で計算した位置。Tokenは先頭から順に読むので続きから数える
+	base Position
+	last Position
+}
+
+func newSourceReader(r io.Reader) *sourceReader {
+	start := Position{Line: 1, Column: 1}
+	return &sourceReader{r: r, base: start, last: start}
+}
+
+func (src *sourceReader) Read(p []byte) (int, error) {
+	n, err := src.r.Read(p)
+	src.buf = append(src.buf, p[:n]...)
+	return n, err
+}
+
+// end は今までに読んだバイト数
+func (src *sourceReader) end() int {
+	return src.offset + len(src.buf)
+}
+
+// slice は入力の start から end バイト目までを返す
+func (src *sourceReader) slice(start, end int) []byte {
+	return src.buf[start-src.offset : end-src.offset]
+}
+
+// position は入力の offset バイト目の行と列を返す。offset は捨てた範囲より後でなければならない
+func (src *sourceReader) position(offset int) Position {
+	if offset < src.last.Offset {
+		src.last = src.base
+	}
+
+	for _, b := range src.slice(src.last.Offset, offset) {
+		if b == '\n' {
+			src.last.Line++
+			src.last.Column = 1
+		} else if utf8.RuneStart(b) {
+			src.last.Column++
+		}
+	}
+	src.last.Offset = offset
+
+	return src.last
+}
+
+// discard は入力の offset バイト目より前の内容を、行と列を数えてから捨てる
+func (src *sourceReader) discard(offset int) {
+	if offset <= src.offset {
+		return
+	}
+
+	src.base = src.position(offset)
+	src.buf = append(src.buf[:0], src.buf[offset-src.offset:]...)
+	src.offset = offset
+}
+
+func (src *sourceReader) errorAt(tokenizer *html.Tokenizer, err error) error {
+	// 現在のTokenの開始位置
+	offset := src.end() - len(tokenizer.Buffered()) - len(tokenizer.Raw())
+	if offset < src.offset {
+		offset = src.offset
+	}
+	position := src.position(offset)
+
+	return &ParseError{Offset: position.Offset, Line: position.Line, Column: position.Column, Err: err}
+}
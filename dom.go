@@ -23,15 +23,41 @@ type Token interface {
 	Type() TokenType
 	Parent() Tag
 	BuildHTML(buf *bytes.Buffer)
+	BuildHTMLWithOptions(buf *bytes.Buffer, opts *BuildOptions)
 
 	Tag() Tag
 	TextToken() TextToken
 }
 
+var _ Serializer = &BuildOptions{}
+
+// BuildOptions は BuildHTMLWithOptions の出力を変える。nil なら BuildHTML と同じ
+type BuildOptions struct {
+	// XHTML は XML としても HTML としても読める(polyglot)形で出力する。
+	// void 要素は自己終了し、属性は全て値を引用符で囲み(checked="checked")、名前は小文字にし、
+	// 文字列と属性値をエスケープし、script と style の中身は CDATA で囲み、<html> に名前空間を付ける。
+	XHTML bool
+	// AttrStyle は属性の引用符と空の値の書き方。XHTML では使わない
+	AttrStyle AttrStyle
+	// SortAttrs は属性を名前順に並べる
+	SortAttrs bool
+	// Entities は文字列と属性値の文字参照の書き方。XHTML では EntityNumeric だけを使う
+	Entities EntityPolicy
+	// Lossless は ConverterOptions.SetLossless(true) で Parse したTokenのうち、書き換えられていないものを元の文字列のまま出力する
+	Lossless bool
+}
+
+// Serialize は token を opts で出力する。ConverterOptions.SetSerializer に渡せる
+func (opts *BuildOptions) Serialize(buf *bytes.Buffer, token Token) error {
+	token.BuildHTMLWithOptions(buf, opts)
+	return nil
+}
+
 type Tag interface {
 	Token
 
 	IsDocumentRoot() bool
+	IsSelfClosing() bool
 
 	Name() string
 	Tokens() []Token
@@ -40,8 +66,10 @@ type Tag interface {
 	AddChildTokens(childlen ...Token)
 	UnshiftChileToken(token Token)
 	ReplateChildToken(from Token, to Token)
+	RemoveChildToken(token Token)
 	GetElementsByTagName(tagName string) []Tag
 	FindAncestor(tagName string) Tag
+	TextContent() string
 
 	Attrs() []*Attr
 	SetAttrs(attrs []*Attr)
@@ -94,6 +122,8 @@ type tagImpl struct {
 	selfClosing  bool
 	tokens       []Token
 	attrs        []*Attr
+	source       *tagSource
+	position     *SourceRange
 }
 
 func (tag *tagImpl) Type() TokenType {
@@ -105,25 +135,20 @@ func (tag *tagImpl) Parent() Tag {
 }
 
 func (tag *tagImpl) BuildHTML(buf *bytes.Buffer) {
+	tag.BuildHTMLWithOptions(buf, nil)
+}
+
+func (tag *tagImpl) BuildHTMLWithOptions(buf *bytes.Buffer, opts *BuildOptions) {
+	if opts != nil && opts.XHTML {
+		buildXHTML(buf, tag, opts)
+		return
+	}
+
 	// root node doesn't have name & attrs.
-	if tag.name != "" {
-		buf.WriteString("<")
-		buf.WriteString(tag.name)
-
-		for _, attr := range tag.attrs {
-			buf.WriteString(" ")
-			buf.WriteString(attr.Key)
-			if attr.Value != "" {
-				buf.WriteString("=\"")
-				buf.WriteString(attr.Value)
-				buf.WriteString("\"")
-			}
-		}
-		if tag.selfClosing {
-			buf.WriteString("/>")
-		} else {
-			buf.WriteString(">")
-		}
+	if opts != nil && opts.Lossless && tag.source.matches(tag) {
+		buf.Write(tag.source.start)
+	} else if tag.name != "" {
+		buildStartTagWithOptions(buf, tag, opts)
 	}
 
 	if tag.selfClosing {
@@ -139,16 +164,58 @@ func (tag *tagImpl) BuildHTML(buf *bytes.Buffer) {
 	}
 
 	for _, token := range tag.tokens {
-		token.BuildHTML(buf)
+		token.BuildHTMLWithOptions(buf, opts)
+	}
+
+	if opts != nil && opts.Lossless && tag.source.matchesEnd(tag) {
+		buf.Write(tag.source.end)
+	} else if tag.name != "" {
+		buildEndTag(buf, tag)
 	}
+}
+
+func buildStartTag(buf *bytes.Buffer, tag Tag) {
+	buildStartTagWithOptions(buf, tag, nil)
+}
+
+func buildStartTagWithOptions(buf *bytes.Buffer, tag Tag, opts *BuildOptions) {
+	buf.WriteString("<")
+	buf.WriteString(tag.Name())
 
-	if tag.name != "" {
-		buf.WriteString("</")
-		buf.WriteString(tag.name)
+	attrs := tag.Attrs()
+	if opts != nil && opts.SortAttrs {
+		attrs = sortedAttrs(attrs)
+	}
+	for _, attr := range attrs {
+		buf.WriteString(" ")
+		if opts == nil {
+			buildAttr(buf, attr)
+		} else {
+			buildAttrWithStyle(buf, encodeAttr(tag, attr, opts), opts.AttrStyle)
+		}
+	}
+	if tag.IsSelfClosing() {
+		buf.WriteString("/>")
+	} else {
 		buf.WriteString(">")
 	}
 }
 
+func buildAttr(buf *bytes.Buffer, attr *Attr) {
+	buf.WriteString(attr.Key)
+	if attr.Value != "" || attr.HasValue {
+		buf.WriteString("=\"")
+		buf.WriteString(attr.Value)
+		buf.WriteString("\"")
+	}
+}
+
+func buildEndTag(buf *bytes.Buffer, tag Tag) {
+	buf.WriteString("</")
+	buf.WriteString(tag.Name())
+	buf.WriteString(">")
+}
+
 func (tag *tagImpl) setParent(parent Tag) {
 	current := tag.Parent()
 	for {
@@ -176,6 +243,10 @@ func (tag *tagImpl) IsDocumentRoot() bool {
 	return tag.documentRoot
 }
 
+func (tag *tagImpl) IsSelfClosing() bool {
+	return tag.selfClosing
+}
+
 func (tag *tagImpl) Name() string {
 	return tag.name
 }
@@ -226,6 +297,18 @@ func (tag *tagImpl) ReplateChildToken(from Token, to Token) {
 	}
 }
 
+func (tag *tagImpl) RemoveChildToken(token Token) {
+	newTokens := make([]Token, 0, len(tag.tokens))
+	for _, child := range tag.tokens {
+		if child == token {
+			token.setParent(nil)
+			continue
+		}
+		newTokens = append(newTokens, child)
+	}
+	tag.tokens = newTokens
+}
+
 func (tag *tagImpl) Attrs() []*Attr {
 	return tag.attrs
 }
@@ -327,12 +410,30 @@ func (tag *tagImpl) FindAncestor(tagName string) Tag {
 	return nil
 }
 
+func (tag *tagImpl) TextContent() string {
+	buf := bytes.NewBufferString("")
+	for _, token := range tag.tokens {
+		switch token.Type() {
+		case TypeTagToken:
+			buf.WriteString(token.Tag().TextContent())
+		case TypeTextToken:
+			buf.WriteString(token.TextToken().Text())
+		}
+	}
+
+	return buf.String()
+}
+
 var _ TextToken = &textTokenImpl{}
 
 type textTokenImpl struct {
 	parent    Tag
 	tokenType TokenType
 	text      string
+	// source と sourceText は Lossless で Parse した時の元の文字列と、その時の text
+	source     []byte
+	sourceText string
+	position   *SourceRange
 }
 
 func (textToken *textTokenImpl) setParent(parent Tag) {
@@ -348,13 +449,34 @@ func (textToken *textTokenImpl) Parent() Tag {
 }
 
 func (textToken *textTokenImpl) BuildHTML(buf *bytes.Buffer) {
+	textToken.BuildHTMLWithOptions(buf, nil)
+}
+
+func (textToken *textTokenImpl) BuildHTMLWithOptions(buf *bytes.Buffer, opts *BuildOptions) {
+	if opts != nil && opts.XHTML {
+		buildXHTMLText(buf, textToken, opts)
+		return
+	}
+	unchanged := textToken.source != nil && textToken.text == textToken.sourceText
+	if opts != nil && opts.Lossless && unchanged {
+		buf.Write(textToken.source)
+		return
+	}
+
 	switch textToken.tokenType {
 	case TypeDoctypeToken:
 		buf.WriteString("<!DOCTYPE ")
 		buf.WriteString(textToken.text)
 		buf.WriteString(">")
 	case TypeTextToken:
-		buf.WriteString(textToken.text)
+		switch {
+		case opts == nil || isRawTextElement(textToken.parent):
+			buf.WriteString(textToken.text)
+		case opts.Entities == EntityPreserve && unchanged:
+			buf.Write(textToken.source)
+		default:
+			buf.WriteString(EncodeEntities(textToken.text, opts.Entities, false))
+		}
 	case TypeCommentToken:
 		buf.WriteString("<!--")
 		buf.WriteString(textToken.text)
@@ -374,7 +496,12 @@ func (textToken *textTokenImpl) Text() string {
 	return textToken.text
 }
 
+// Attr は要素の属性。Quote と HasValue は Parse した時の書き方で、出力にだけ使う
 type Attr struct {
 	Key   string
 	Value string
+	// Quote は Parse した HTML で値を囲んでいた引用符。コードで追加した属性は AttrQuoteDefault
+	Quote AttrQuote
+	// HasValue は Parse した HTML で値が書かれていたか。alt と alt="" を区別する
+	HasValue bool
 }
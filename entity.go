@@ -0,0 +1,104 @@
+package html2html
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+)
+
+// EntityPolicy は BuildHTMLWithOptions で文字列と属性値をどう文字参照にするか
+type EntityPolicy int
+
+const (
+	// EntityRaw は BuildHTML と同じく、Parse で展開した文字列をそのまま出力する
+	EntityRaw EntityPolicy = iota
+	// EntityMinimal は & < > (属性値では & と ")だけを文字参照にする
+	EntityMinimal
+	// EntityNumeric は EntityMinimal に加えて ASCII 以外の文字を全て数値文字参照にする
+	EntityNumeric
+	// EntityNamed は EntityMinimal に加えて ASCII 以外の文字を名前付き文字参照に、名前が無ければ数値文字参照にする
+	EntityNamed
+	// EntityPreserve は ConverterOptions.SetLossless(true) で Parse した元の書き方を保つ。
+	// 書き換えられた文字列と、元の書き方がわからないものは EntityMinimal で出力する
+	EntityPreserve
+)
+
+// RawTextElements の中身は文字参照として解釈されないので、EntityPolicy に関わらずそのまま出力する
+var RawTextElements = []string{"script", "style", "xmp", "iframe", "noembed", "noframes", "plaintext"}
+
+// EncodeEntities は text を policy に従って文字参照にする。attr が true なら属性値として扱う。
+// EntityPreserve は元の書き方がわからないので EntityMinimal と同じになる。
+func EncodeEntities(text string, policy EntityPolicy, attr bool) string {
+	if policy == EntityRaw {
+		return text
+	}
+
+	buf := bytes.NewBufferString("")
+	for _, r := range text {
+		switch {
+		case r == '&':
+			buf.WriteString("&amp;")
+		case r == '"' && attr:
+			buf.WriteString("&quot;")
+		case (r == '<' || r == '>') && !attr:
+			if r == '<' {
+				buf.WriteString("&lt;")
+			} else {
+				buf.WriteString("&gt;")
+			}
+		case r > 0x7f && policy == EntityNamed && htmlEntityNames[r] != "":
+			buf.WriteString("&")
+			buf.WriteString(htmlEntityNames[r])
+			buf.WriteString(";")
+		case r > 0x7f && (policy == EntityNamed || policy == EntityNumeric):
+			buf.WriteString("&#")
+			buf.WriteString(strconv.Itoa(int(r)))
+			buf.WriteString(";")
+		default:
+			buf.WriteRune(r)
+		}
+	}
+
+	return buf.String()
+}
+
+// encodeNonASCII は ASCII 以外の文字を数値文字参照にする
+func encodeNonASCII(text string) string {
+	buf := bytes.NewBufferString("")
+	for _, r := range text {
+		if r > 0x7f {
+			buf.WriteString("&#")
+			buf.WriteString(strconv.Itoa(int(r)))
+			buf.WriteString(";")
+		} else {
+			buf.WriteRune(r)
+		}
+	}
+
+	return buf.String()
+}
+
+// encodeAttr は opts に従って attr の値を文字参照にした Attr を返す
+func encodeAttr(tag Tag, attr *Attr, opts *BuildOptions) *Attr {
+	if opts.Entities == EntityRaw {
+		return attr
+	}
+
+	encoded := *attr
+	if raw, ok := rawAttrValue(tag, attr); ok && opts.Entities == EntityPreserve {
+		// 元の値は単一引用符か引用符無しで書かれていたかもしれない
+		if opts.AttrStyle != AttrStylePreserve || attr.Quote != AttrQuoteSingle {
+			raw = strings.Replace(raw, `"`, "&quot;", -1)
+		}
+		encoded.Value = raw
+	} else {
+		encoded.Value = EncodeEntities(attr.Value, opts.Entities, true)
+	}
+
+	return &encoded
+}
+
+// isRawTextElement は tag の中の文字列が文字参照として解釈されないかを返す
+func isRawTextElement(tag Tag) bool {
+	return tag != nil && containsString(RawTextElements, tag.Name())
+}
@@ -0,0 +1,63 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEncodeEntities(t *testing.T) {
+	text := `Café <b> & "ü" 😀`
+
+	expects := []struct {
+		policy   EntityPolicy
+		attr     bool
+		expected string
+	}{
+		{EntityRaw, false, text},
+		{EntityMinimal, false, `Café &lt;b&gt; &amp; "ü" 😀`},
+		{EntityMinimal, true, `Café <b> &amp; &quot;ü&quot; 😀`},
+		{EntityNumeric, false, `Caf&#233; &lt;b&gt; &amp; "&#252;" &#128512;`},
+		{EntityNamed, false, `Caf&eacute; &lt;b&gt; &amp; "&uuml;" &#128512;`},
+		{EntityPreserve, false, `Café &lt;b&gt; &amp; "ü" 😀`},
+	}
+	for _, expect := range expects {
+		if result := EncodeEntities(text, expect.policy, expect.attr); result != expect.expected {
+			t.Log("expected:\n", expect.expected, "actual:\n", result)
+			t.Fail()
+		}
+	}
+}
+
+func TestBuildOptions_entities(t *testing.T) {
+	html := `<p title="caf&eacute; &#34;x&#34;">Caf&#xE9; &amp; cr&egrave;me</p><script>if (a < b) {}</script>`
+
+	conv := NewConverter()
+	conv.(ConverterOptions).SetLossless(true)
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := root.GetElementsByTagName("p")[0]
+	p.AddText(" ü")
+
+	expects := []struct {
+		opts     *BuildOptions
+		expected string
+	}{
+		{&BuildOptions{Entities: EntityNamed}, `<p title="caf&eacute; &quot;x&quot;">Caf&eacute; &amp; cr&egrave;me &uuml;</p><script>if (a < b) {}</script>`},
+		{&BuildOptions{Entities: EntityNumeric}, `<p title="caf&#233; &quot;x&quot;">Caf&#233; &amp; cr&#232;me &#252;</p><script>if (a < b) {}</script>`},
+		{&BuildOptions{Entities: EntityPreserve}, `<p title="caf&eacute; &#34;x&#34;">Caf&#xE9; &amp; cr&egrave;me ü</p><script>if (a < b) {}</script>`},
+		{&BuildOptions{XHTML: true, Entities: EntityNumeric}, `<p title="caf&#233; &quot;x&quot;">Caf&#233; &amp; cr&#232;me &#252;</p><script>//<![CDATA[
+if (a < b) {}
+//]]></script>`},
+	}
+	for _, expect := range expects {
+		buf := bytes.NewBufferString("")
+		root.BuildHTMLWithOptions(buf, expect.opts)
+		if buf.String() != expect.expected {
+			t.Log("expected:\n", expect.expected, "actual:\n", buf.String())
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+var _ Serializer = &Formatter{}
+
+// WhitespaceSensitiveElements の中身は空白も含めてそのまま出力する
+var WhitespaceSensitiveElements = []string{"pre", "textarea", "script", "style"}
+
+// formatterBlockElements はブロック要素ではないが、Formatter で1行を使う要素
+var formatterBlockElements = []string{"title", "meta", "link", "base", "script", "style", "noscript", "template"}
+
+// Formatter はブロック要素を字下げして1行ずつ出力する Serializer。
+// インライン要素と文字列は空白をまとめて1行に並べ、pre などの中身は変えない。
+// 出力を Parse して Formatter に通しても同じ結果になる。
+type Formatter struct {
+	Indent string
+	// MaxLineWidth を超えるブロック要素の開始タグは属性を1つずつ改行する。0 なら改行しない
+	MaxLineWidth int
+}
+
+func NewFormatter() *Formatter {
+	return &Formatter{Indent: "  ", MaxLineWidth: 100}
+}
+
+func (formatter *Formatter) Serialize(buf *bytes.Buffer, token Token) error {
+	if token.Type() == TypeTagToken && token.Tag().IsDocumentRoot() {
+		formatter.children(buf, token.Tag(), 0)
+	} else {
+		formatter.block(buf, token, 0)
+	}
+
+	return nil
+}
+
+func (formatter *Formatter) children(buf *bytes.Buffer, tag Tag, depth int) {
+	var run []Token
+	for _, token := range tag.Tokens() {
+		if isFormatterBlock(token) {
+			formatter.inlineRun(buf, run, depth)
+			run = nil
+			formatter.block(buf, token, depth)
+		} else {
+			run = append(run, token)
+		}
+	}
+	formatter.inlineRun(buf, run, depth)
+}
+
+func (formatter *Formatter) block(buf *bytes.Buffer, token Token, depth int) {
+	if token.Type() != TypeTagToken {
+		formatter.inlineRun(buf, []Token{token}, depth)
+		return
+	}
+
+	tag := token.Tag()
+	indent := strings.Repeat(formatter.Indent, depth)
+	buf.WriteString(indent)
+	formatter.startTag(buf, tag, indent)
+
+	switch {
+	case tag.IsSelfClosing() || (IsVoidElement(tag) && len(tag.Tokens()) == 0):
+	case containsString(WhitespaceSensitiveElements, tag.Name()):
+		for _, child := range tag.Tokens() {
+			child.BuildHTML(buf)
+		}
+		buildEndTag(buf, tag)
+	case !hasFormatterBlock(tag):
+		line := bytes.NewBufferString("")
+		writer := &inlineWriter{buf: line}
+		for _, child := range tag.Tokens() {
+			writer.write(child)
+		}
+		buf.WriteString(strings.TrimSpace(line.String()))
+		buildEndTag(buf, tag)
+	default:
+		buf.WriteString("\n")
+		formatter.children(buf, tag, depth+1)
+		buf.WriteString(indent)
+		buildEndTag(buf, tag)
+	}
+	buf.WriteString("\n")
+}
+
+// inlineRun はブロック要素の間のインライン要素と文字列を1行にまとめる。空白だけなら何も出力しない
+func (formatter *Formatter) inlineRun(buf *bytes.Buffer, run []Token, depth int) {
+	line := bytes.NewBufferString("")
+	writer := &inlineWriter{buf: line}
+	for _, token := range run {
+		writer.write(token)
+	}
+
+	if s := strings.TrimSpace(line.String()); s != "" {
+		buf.WriteString(strings.Repeat(formatter.Indent, depth))
+		buf.WriteString(s)
+		buf.WriteString("\n")
+	}
+}
+
+func (formatter *Formatter) startTag(buf *bytes.Buffer, tag Tag, indent string) {
+	line := bytes.NewBufferString("")
+	buildStartTag(line, tag)
+	if formatter.MaxLineWidth <= 0 || len(indent)+line.Len() <= formatter.MaxLineWidth || len(tag.Attrs()) < 2 {
+		buf.Write(line.Bytes())
+		return
+	}
+
+	buf.WriteString("<")
+	buf.WriteString(tag.Name())
+	for _, attr := range tag.Attrs() {
+		buf.WriteString("\n")
+		buf.WriteString(indent)
+		buf.WriteString(formatter.Indent)
+		buildAttr(buf, attr)
+	}
+	if tag.IsSelfClosing() {
+		buf.WriteString("/>")
+	} else {
+		buf.WriteString(">")
+	}
+}
+
+// inlineWriter は文字列の連続する空白を1つにまとめながら出力する
+type inlineWriter struct {
+	buf   *bytes.Buffer
+	space bool
+}
+
+func (writer *inlineWriter) write(token Token) {
+	switch token.Type() {
+	case TypeTextToken:
+		for _, r := range token.TextToken().Text() {
+			if unicode.IsSpace(r) {
+				if !writer.space {
+					writer.buf.WriteByte(' ')
+				}
+				writer.space = true
+				continue
+			}
+			writer.buf.WriteRune(r)
+			writer.space = false
+		}
+
+	case TypeTagToken:
+		tag := token.Tag()
+		if containsString(WhitespaceSensitiveElements, tag.Name()) {
+			tag.BuildHTML(writer.buf)
+			writer.space = false
+			return
+		}
+
+		buildStartTag(writer.buf, tag)
+		if tag.IsSelfClosing() || (IsVoidElement(tag) && len(tag.Tokens()) == 0) {
+			return
+		}
+		for _, child := range tag.Tokens() {
+			writer.write(child)
+		}
+		buildEndTag(writer.buf, tag)
+
+	default:
+		token.BuildHTML(writer.buf)
+		writer.space = false
+	}
+}
+
+// isFormatterBlock は token を独立した行に出力するかを返す
+func isFormatterBlock(token Token) bool {
+	if token.Type() == TypeDoctypeToken {
+		return true
+	}
+	if token.Type() != TypeTagToken {
+		return false
+	}
+
+	tag := token.Tag()
+	if IsBlockElement(tag) || containsString(formatterBlockElements, tag.Name()) {
+		return true
+	}
+
+	return hasFormatterBlock(tag)
+}
+
+func hasFormatterBlock(tag Tag) bool {
+	if containsString(WhitespaceSensitiveElements, tag.Name()) {
+		return false
+	}
+	for _, token := range tag.Tokens() {
+		if isFormatterBlock(token) {
+			return true
+		}
+	}
+
+	return false
+}
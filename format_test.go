@@ -0,0 +1,76 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestFormatter(t *testing.T) {
+	html := `<!DOCTYPE html><html><head><title>Hello</title><meta charset="utf-8"></head><body><div class="a"><p>Hello,   <b>big</b>
+world!</p><pre>  keep
+   this  </pre><ul><li>one</li><li><a href="#">two</a></li></ul><form action="https://example.com/very/long/path/to/the/form/handler" method="post" class="a-long-class-name"><input name="q"></form></div>text <i>after</i> div</body></html>`
+
+	conv := NewConverter()
+	conv.(ConverterOptions).SetSerializer(NewFormatter())
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Hello</title>
+    <meta charset="utf-8">
+  </head>
+  <body>
+    <div class="a">
+      <p>Hello, <b>big</b> world!</p>
+      <pre>  keep
+   this  </pre>
+      <ul>
+        <li>one</li>
+        <li><a href="#">two</a></li>
+      </ul>
+      <form
+        action="https://example.com/very/long/path/to/the/form/handler"
+        method="post"
+        class="a-long-class-name"><input name="q"></form>
+    </div>
+    text <i>after</i> div
+  </body>
+</html>
+`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+
+	// 冪等
+	again, err := conv.Convert(strings.NewReader(result))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != result {
+		t.Log("expected:\n", result, "actual:\n", again)
+		t.Fail()
+	}
+}
+
+func TestFormatter_programmatic(t *testing.T) {
+	ul := CreateElement("ul")
+	for _, text := range []string{"a", "b"} {
+		li := CreateElement("li")
+		li.AddText(text)
+		ul.AddChildTokens(li)
+	}
+
+	buf := bytes.NewBufferString("")
+	if err := NewFormatter().Serialize(buf, ul); err != nil {
+		t.Fatal(err)
+	}
+	if v := buf.String(); v != "<ul>\n  <li>a</li>\n  <li>b</li>\n</ul>\n" {
+		t.Error("unexpected", v)
+	}
+}
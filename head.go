@@ -0,0 +1,281 @@
+package html2html
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Head は document root の <head> を編集する。
+// Set で始まるメソッドは同じキーの要素を1つにまとめて値を置き換え、無ければ <head> の末尾に追加する。
+type Head struct {
+	tag Tag
+}
+
+// HeadOf は root の <head> を返す。
+// <head> が無ければ <html> の先頭に、<html> も無ければ root の DOCTYPE とコメントの後に作る。
+func HeadOf(root Tag) *Head {
+	if heads := root.GetElementsByTagName("head"); len(heads) != 0 {
+		return &Head{tag: heads[0]}
+	}
+
+	head := CreateElement("head")
+	parent := root
+	if htmls := root.GetElementsByTagName("html"); len(htmls) != 0 {
+		parent = htmls[0]
+	}
+
+	idx := 0
+	for idx < len(parent.Tokens()) {
+		token := parent.Tokens()[idx]
+		if token.Type() == TypeTagToken || (token.Type() == TypeTextToken && strings.TrimSpace(token.TextToken().Text()) != "") {
+			break
+		}
+		idx++
+	}
+	tokens := append([]Token(nil), parent.Tokens()[:idx]...)
+	tokens = append(tokens, head)
+	parent.SetTokens(append(tokens, parent.Tokens()[idx:]...))
+
+	return &Head{tag: head}
+}
+
+// Tag は <head> の要素を返す
+func (head *Head) Tag() Tag {
+	return head.tag
+}
+
+// SetTitle は <title> の文字列を置き換える
+func (head *Head) SetTitle(title string) {
+	tag := head.upsert("title", func(tag Tag) bool { return true })
+	tag.SetTokens([]Token{CreateTextToken(title)})
+}
+
+// SetMeta は <meta name="name"> の content を置き換える。
+// twitter: で始まる name は MetadataExtractor と同じく <meta property="name"> も同じものとして扱う
+func (head *Head) SetMeta(name, content string) {
+	isTwitter := strings.HasPrefix(strings.ToLower(name), "twitter:")
+	tag := head.upsert("meta", func(tag Tag) bool {
+		return tag.HasAttrValueCaseInsensitive("name", name) || (isTwitter && tag.HasAttrValueCaseInsensitive("property", name))
+	})
+	if !tag.HasAttrValueCaseInsensitive("property", name) {
+		setAttr(tag, "name", name)
+	}
+	setAttr(tag, "content", content)
+}
+
+// SetProperty は <meta property="property"> の content を contents に置き換える。
+// og:image のように繰り返す property には複数の content を渡す。contents が空なら取り除く
+func (head *Head) SetProperty(property string, contents ...string) {
+	head.setRepeated("meta", func(tag Tag) bool {
+		return tag.HasAttrValueCaseInsensitive("property", property)
+	}, len(contents), func(tag Tag, idx int) {
+		setAttr(tag, "property", property)
+		setAttr(tag, "content", contents[idx])
+	})
+}
+
+// SetLink は rel の <link> の href を置き換える。
+// keyAttrs は "hreflang", "ja" のような属性名と値の組で、rel が同じでも keyAttrs が違う <link> は別のものとして扱う
+func (head *Head) SetLink(rel, href string, keyAttrs ...string) {
+	if len(keyAttrs)%2 != 0 {
+		panic(fmt.Sprintf("odd number of keyAttrs: %v", keyAttrs))
+	}
+
+	tag := head.upsert("link", func(tag Tag) bool {
+		if !containsString(strings.Fields(strings.ToLower(attrValue(tag, "rel"))), strings.ToLower(rel)) {
+			return false
+		}
+		for i := 0; i < len(keyAttrs); i += 2 {
+			if !tag.HasAttrValueCaseInsensitive(keyAttrs[i], keyAttrs[i+1]) {
+				return false
+			}
+		}
+		return true
+	})
+	setAttr(tag, "rel", rel)
+	for i := 0; i < len(keyAttrs); i += 2 {
+		setAttr(tag, keyAttrs[i], keyAttrs[i+1])
+	}
+	setAttr(tag, "href", href)
+}
+
+// SetCanonical は <link rel="canonical"> の href を置き換える
+func (head *Head) SetCanonical(href string) {
+	head.SetLink("canonical", href)
+}
+
+// SetJSONLD は <script type="application/ld+json" id="key"> の JSON を value に置き換える。
+// key は script の id 属性で、同じ key で書き込むと前の値を置き換える
+func (head *Head) SetJSONLD(key string, value interface{}) error {
+	if key == "" {
+		return fmt.Errorf("JSON-LD key must not be empty")
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if data[0] != '{' {
+		return fmt.Errorf("JSON-LD must be an object: %s", data)
+	}
+
+	tag := head.upsert("script", func(tag Tag) bool {
+		return isJSONLD(tag, key)
+	})
+	setAttr(tag, "type", "application/ld+json")
+	setAttr(tag, "id", key)
+	// json.Marshal は < > & をエスケープするので </script> が現れることはない
+	tag.SetTokens([]Token{CreateTextToken(string(data))})
+
+	return nil
+}
+
+// RemoveJSONLD は SetJSONLD で key に書き込んだ script を取り除く
+func (head *Head) RemoveJSONLD(key string) {
+	head.setRepeated("script", func(tag Tag) bool {
+		return isJSONLD(tag, key)
+	}, 0, nil)
+}
+
+// Apply は metadata の title, description, canonical, OpenGraph, Twitter, JSON-LD を head に書き込む。
+// 空の値は書き込まない。JSON-LD は順に jsonld-0, jsonld-1 ... の key で書き込み、前に書き込んだ残りは取り除く
+func (head *Head) Apply(metadata *Metadata) error {
+	if metadata.Title != "" {
+		head.SetTitle(metadata.Title)
+	}
+	if metadata.Description != "" {
+		head.SetMeta("description", metadata.Description)
+	}
+	if metadata.Canonical != "" {
+		head.SetCanonical(metadata.Canonical)
+	}
+	for _, key := range sortedKeys(metadata.OpenGraph) {
+		head.SetProperty(key, metadata.OpenGraph[key]...)
+	}
+	for _, key := range sortedStringKeys(metadata.Twitter) {
+		head.SetMeta(key, metadata.Twitter[key])
+	}
+	for idx, object := range metadata.JSONLD {
+		if err := head.SetJSONLD(jsonLDKey(idx), object); err != nil {
+			return err
+		}
+	}
+	for idx := len(metadata.JSONLD); head.hasJSONLD(jsonLDKey(idx)); idx++ {
+		head.RemoveJSONLD(jsonLDKey(idx))
+	}
+
+	return nil
+}
+
+func jsonLDKey(idx int) string {
+	return fmt.Sprintf("jsonld-%d", idx)
+}
+
+func (head *Head) hasJSONLD(key string) bool {
+	for _, tag := range head.tag.GetElementsByTagName("script") {
+		if isJSONLD(tag, key) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isJSONLD は tag が SetJSONLD で key に書き込んだ script かを返す
+func isJSONLD(tag Tag, key string) bool {
+	return tag.HasAttrValueCaseInsensitive("type", "application/ld+json") && tag.HasAttrValue("id", key)
+}
+
+// upsert は match する name の要素を1つ残して返す。無ければ <head> の末尾に追加する
+func (head *Head) upsert(name string, match func(tag Tag) bool) Tag {
+	var tag Tag
+	head.setRepeated(name, match, 1, func(t Tag, idx int) {
+		tag = t
+	})
+
+	return tag
+}
+
+// setRepeated は match する name の要素を count 個にして、順に set を呼ぶ。
+// 足りない要素は最後に match した要素の後、無ければ <head> の末尾に追加し、余った要素は取り除く
+func (head *Head) setRepeated(name string, match func(tag Tag) bool, count int, set func(tag Tag, idx int)) {
+	var matched []Tag
+	for _, tag := range head.tag.GetElementsByTagName(name) {
+		if match(tag) {
+			matched = append(matched, tag)
+		}
+	}
+
+	for idx := 0; idx < count; idx++ {
+		if idx < len(matched) {
+			set(matched[idx], idx)
+			continue
+		}
+
+		tag := CreateElement(name)
+		if idx == 0 {
+			head.tag.AddChildTokens(tag)
+		} else {
+			insertAfter(matched[idx-1], tag)
+		}
+		matched = append(matched, tag)
+		set(tag, idx)
+	}
+
+	for _, tag := range matched[count:] {
+		tag.Parent().RemoveChildToken(tag)
+	}
+}
+
+func insertAfter(sibling Tag, token Token) {
+	parent := sibling.Parent()
+	var tokens []Token
+	for _, child := range parent.Tokens() {
+		tokens = append(tokens, child)
+		if child == Token(sibling) {
+			tokens = append(tokens, token)
+		}
+	}
+	parent.SetTokens(tokens)
+}
+
+// setAttr は attrKey の属性を最初の1つだけにして値を value にする。無ければ末尾に追加する
+func setAttr(tag Tag, attrKey, value string) {
+	attrs := make([]*Attr, 0, len(tag.Attrs()))
+	found := false
+	for _, attr := range tag.Attrs() {
+		if attr.Key == attrKey {
+			if found {
+				continue
+			}
+			found = true
+			attr.Value = value
+		}
+		attrs = append(attrs, attr)
+	}
+	if !found {
+		attrs = append(attrs, &Attr{Key: attrKey, Value: value})
+	}
+	tag.SetAttrs(attrs)
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func sortedStringKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
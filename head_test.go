@@ -0,0 +1,141 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHead(t *testing.T) {
+	html := `<!DOCTYPE html><html><head><title>Old</title>
+<meta name="description" content="old"><meta name="Description" content="dup">
+<meta property="og:image" content="/1.png"><meta property="og:title" content="Old">
+<link rel="canonical" href="/old"><link rel="alternate" hreflang="en" href="/en/">
+<meta property="twitter:card" content="summary">
+<script type="application/ld+json" id="article">{"@type": "Article", "headline": "Old", "dateModified": "2016-01-01"}</script>
+<script type="application/ld+json">{"@type": "Organization", "name": "Example"}</script>
+</head><body></body></html>`
+
+	root, err := NewConverter().Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	head := HeadOf(root)
+	head.SetTitle("New")
+	head.SetMeta("description", "new")
+	head.SetProperty("og:image", "/a.png", "/b.png")
+	head.SetProperty("og:title")
+	head.SetCanonical("/new")
+	head.SetLink("alternate", "/ja/", "hreflang", "ja")
+	head.SetMeta("twitter:card", "summary_large_image")
+	if err := head.SetJSONLD("article", map[string]interface{}{"@type": "Article", "headline": "</script>", "dateModified": "2016-02-01"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := head.SetJSONLD("other", map[string]interface{}{"@type": "Organization", "name": "Other"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := head.SetJSONLD("", map[string]interface{}{}); err == nil {
+		t.Error("expected error")
+	}
+	if err := head.SetJSONLD("list", []string{"a"}); err == nil {
+		t.Error("expected error")
+	}
+
+	buf := bytes.NewBufferString("")
+	root.BuildHTML(buf)
+	expected := `<!DOCTYPE html><html><head><title>New</title>
+<meta name="description" content="new">
+<meta property="og:image" content="/a.png"><meta property="og:image" content="/b.png">
+<link rel="canonical" href="/new"><link rel="alternate" hreflang="en" href="/en/">
+<meta property="twitter:card" content="summary_large_image">
+<script type="application/ld+json" id="article">{"@type":"Article","dateModified":"2016-02-01","headline":"\u003c/script\u003e"}</script>
+<script type="application/ld+json">{"@type": "Organization", "name": "Example"}</script>
+<link rel="alternate" hreflang="ja" href="/ja/"><script type="application/ld+json" id="other">{"@type":"Organization","name":"Other"}</script></head><body></body></html>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+}
+
+func TestHeadOf_create(t *testing.T) {
+	root, err := NewConverter().Parse(strings.NewReader(`<!DOCTYPE html>
+<html>
+<body><p>text</p></body></html>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	HeadOf(root).SetCanonical("https://example.com/")
+
+	buf := bytes.NewBufferString("")
+	root.BuildHTML(buf)
+	expected := `<!DOCTYPE html>
+<html>
+<head><link rel="canonical" href="https://example.com/"></head><body><p>text</p></body></html>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+
+	root, err = NewConverter().Parse(strings.NewReader(`<p>fragment</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	HeadOf(root).SetTitle("Fragment")
+
+	buf.Reset()
+	root.BuildHTML(buf)
+	expected = `<head><title>Fragment</title></head><p>fragment</p>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+}
+
+func TestHead_Apply(t *testing.T) {
+	source, err := ExtractMetadata(mustParse(t, `<head><title>Page</title><meta name="description" content="desc">
+<link rel="canonical" href="https://example.com/page">
+<meta property="og:title" content="Page"><meta name="twitter:card" content="summary">
+<script type="application/ld+json">{"@type": "WebPage", "dateModified": "2016-01-01"}</script>
+<script type="application/ld+json">{"@type": "Person", "name": "Jane"}</script></head>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := mustParse(t, `<html><head><title>Draft</title><meta property="twitter:card" content="old"></head><body></body></html>`)
+	if err := HeadOf(root).Apply(source); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ExtractMetadata(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Title != "Page" || result.Description != "desc" || result.Canonical != "https://example.com/page" {
+		t.Error("unexpected", result)
+	}
+	if result.OpenGraph["og:title"][0] != "Page" || result.Twitter["twitter:card"] != "summary" {
+		t.Error("unexpected", result)
+	}
+	if len(result.JSONLD) != 2 || result.JSONLD[0]["@type"] != "WebPage" || result.JSONLD[1]["@type"] != "Person" {
+		t.Error("unexpected", result.JSONLD)
+	}
+
+	// 2回書き込んでも増えず、古い値は置き換わる
+	source.JSONLD = source.JSONLD[:1]
+	source.JSONLD[0]["dateModified"] = "2016-02-01"
+	if err := HeadOf(root).Apply(source); err != nil {
+		t.Fatal(err)
+	}
+	if count := len(root.GetElementsByTagName("meta")); count != 3 {
+		t.Error("unexpected", count)
+	}
+	result, err = ExtractMetadata(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.JSONLD) != 1 || result.JSONLD[0]["dateModified"] != "2016-02-01" {
+		t.Error("unexpected", result.JSONLD)
+	}
+}
@@ -0,0 +1,231 @@
+package html2html
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ImageDimensionFunc は画像の src から幅と高さを返す。分からない場合は 0, 0, nil を返す
+type ImageDimensionFunc func(src string) (width int, height int, err error)
+
+// ImageFormat は <picture> に加える <source> の形式
+type ImageFormat struct {
+	// Name は URL テンプレートの {format} に入る名前 (例: "avif")
+	Name string
+	// Type は <source type> の MIME type (例: "image/avif")
+	Type string
+	// URLTemplate はこの形式の URL テンプレート。空なら ImageOptimizer.URLTemplate を使う
+	URLTemplate string
+}
+
+var (
+	ImageFormatAVIF = &ImageFormat{Name: "avif", Type: "image/avif"}
+	ImageFormatWebP = &ImageFormat{Name: "webp", Type: "image/webp"}
+)
+
+// ImageOptimizer は <img> に遅延読み込みの属性と srcset, sizes, width, height を加え、<picture> で他の形式の画像を提供する。
+// 既に書かれている属性は変えない。
+//
+// URL テンプレートの {src} は元の src、{width} は Widths の幅、{format} は ImageFormat.Name で置き換える。
+// src にクエリがあれば {src}? の後のクエリは & でつなぎ、URL の空白とカンマは srcset の区切りにならないよう URL エンコードする。
+//
+//	optimizer := NewImageOptimizer()
+//	optimizer.URLTemplate = "{src}?w={width}&fm={format}"
+//	optimizer.Widths = []int{320, 640, 1280}
+//	optimizer.Sizes = "(max-width: 640px) 100vw, 640px"
+//	optimizer.Formats = []*ImageFormat{ImageFormatAVIF, ImageFormatWebP}
+type ImageOptimizer struct {
+	// Lazy は loading="lazy" を加える。先頭から EagerImages 個の <img> には加えない
+	Lazy        bool
+	EagerImages int
+	// AsyncDecoding は decoding="async" を加える
+	AsyncDecoding bool
+
+	URLTemplate string
+	// FallbackURLTemplate は <img> の srcset の URL テンプレート。
+	// 空なら URLTemplate から {format} を含むクエリパラメータを取り除いて使う
+	FallbackURLTemplate string
+	// Widths が空か URLTemplate が空なら srcset と <picture> は作らない
+	Widths []int
+	Sizes  string
+	// Formats は <picture> の <source> に並べる形式。優先する順に並べる
+	Formats []*ImageFormat
+
+	// Dimensions は width と height が無い <img> の大きさを調べる。片方だけある場合は縦横比からもう片方を決める
+	Dimensions ImageDimensionFunc
+}
+
+func NewImageOptimizer() *ImageOptimizer {
+	return &ImageOptimizer{
+		Lazy:          true,
+		AsyncDecoding: true,
+	}
+}
+
+// Pass は Optimize を実行する Pass を返す
+func (optimizer *ImageOptimizer) Pass() *Pass {
+	return &Pass{
+		Name: "image",
+		Run:  optimizer.Optimize,
+	}
+}
+
+// Optimize は root 以下の全ての <img> を書き換える
+func (optimizer *ImageOptimizer) Optimize(root Tag) error {
+	for idx, img := range root.GetElementsByTagName("img") {
+		if err := optimizer.optimizeImage(img, idx < optimizer.EagerImages); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (optimizer *ImageOptimizer) optimizeImage(img Tag, eager bool) error {
+	if optimizer.Lazy && !eager && !img.HasAttr("loading") {
+		img.AddAttr("loading", "lazy")
+	}
+	if optimizer.AsyncDecoding && !img.HasAttr("decoding") {
+		img.AddAttr("decoding", "async")
+	}
+
+	src := strings.TrimSpace(attrValue(img, "src"))
+	if err := optimizer.fillDimensions(img, src); err != nil {
+		return err
+	}
+
+	// data: URL は別の幅や形式を作れない
+	if src == "" || strings.HasPrefix(strings.ToLower(src), "data:") || optimizer.URLTemplate == "" || len(optimizer.Widths) == 0 {
+		return nil
+	}
+
+	if !img.HasAttr("srcset") {
+		img.AddAttr("srcset", optimizer.srcset(optimizer.fallbackURLTemplate(), src, ""))
+		if optimizer.Sizes != "" && !img.HasAttr("sizes") {
+			img.AddAttr("sizes", optimizer.Sizes)
+		}
+	}
+
+	parent := img.Parent()
+	if len(optimizer.Formats) == 0 || parent == nil || strings.EqualFold(parent.Name(), "picture") {
+		return nil
+	}
+
+	picture := CreateElement("picture")
+	parent.ReplateChildToken(img, picture)
+	for _, format := range optimizer.Formats {
+		template := format.URLTemplate
+		if template == "" {
+			template = optimizer.URLTemplate
+		}
+
+		source := CreateElement("source")
+		source.AddAttr("type", format.Type)
+		source.AddAttr("srcset", optimizer.srcset(template, src, format.Name))
+		if sizes := img.GetAttr("sizes"); sizes != nil {
+			source.AddAttr("sizes", sizes.Value)
+		}
+		picture.AddChildTokens(source)
+	}
+	picture.AddChildTokens(img)
+
+	return nil
+}
+
+func (optimizer *ImageOptimizer) fillDimensions(img Tag, src string) error {
+	width, hasWidth := imageDimension(img, "width")
+	height, hasHeight := imageDimension(img, "height")
+	if optimizer.Dimensions == nil || src == "" || (hasWidth && hasHeight) {
+		return nil
+	}
+
+	naturalWidth, naturalHeight, err := optimizer.Dimensions(src)
+	if err != nil {
+		return fmt.Errorf("image dimensions of %s: %w", src, err)
+	}
+	if naturalWidth <= 0 || naturalHeight <= 0 {
+		return nil
+	}
+
+	switch {
+	case hasWidth:
+		height = width * naturalHeight / naturalWidth
+	case hasHeight:
+		width = height * naturalWidth / naturalHeight
+	default:
+		width, height = naturalWidth, naturalHeight
+	}
+	if !hasWidth {
+		setAttr(img, "width", strconv.Itoa(width))
+	}
+	if !hasHeight {
+		setAttr(img, "height", strconv.Itoa(height))
+	}
+
+	return nil
+}
+
+// srcsetURLEscaper は srcset の区切りと間違えられる空白とカンマを URL エンコードする
+var srcsetURLEscaper = strings.NewReplacer(" ", "%20", "\t", "%09", "\n", "%0A", "\f", "%0C", "\r", "%0D", ",", "%2C")
+
+func (optimizer *ImageOptimizer) srcset(template, src, format string) string {
+	widths := append([]int(nil), optimizer.Widths...)
+	sort.Ints(widths)
+
+	// src のフラグメントはテンプレートのクエリの後に付け直す
+	fragment := ""
+	if idx := strings.Index(src, "#"); idx != -1 {
+		src, fragment = src[:idx], src[idx:]
+	}
+	if strings.Contains(src, "?") {
+		template = strings.Replace(template, "{src}?", "{src}&", -1)
+	}
+
+	candidates := make([]string, 0, len(widths))
+	for _, width := range widths {
+		url := strings.NewReplacer("{src}", src, "{width}", strconv.Itoa(width), "{format}", format).Replace(template) + fragment
+		candidates = append(candidates, fmt.Sprintf("%s %dw", srcsetURLEscaper.Replace(url), width))
+	}
+
+	return strings.Join(candidates, ", ")
+}
+
+// fallbackURLTemplate は <img> の srcset に使う URL テンプレートを返す
+func (optimizer *ImageOptimizer) fallbackURLTemplate() string {
+	if optimizer.FallbackURLTemplate != "" {
+		return optimizer.FallbackURLTemplate
+	}
+
+	template := optimizer.URLTemplate
+	idx := strings.Index(template, "?")
+	if idx == -1 {
+		return template
+	}
+	var params []string
+	for _, param := range strings.Split(template[idx+1:], "&") {
+		if !strings.Contains(param, "{format}") {
+			params = append(params, param)
+		}
+	}
+	if len(params) == 0 {
+		return template[:idx]
+	}
+
+	return template[:idx+1] + strings.Join(params, "&")
+}
+
+// imageDimension は width, height 属性の値を返す。数値でない値は無いものとして扱う
+func imageDimension(img Tag, attrKey string) (int, bool) {
+	attr := img.GetAttr(attrKey)
+	if attr == nil {
+		return 0, false
+	}
+	value, err := strconv.Atoi(strings.TrimSpace(attr.Value))
+	if err != nil || value <= 0 {
+		return 0, false
+	}
+
+	return value, true
+}
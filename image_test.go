@@ -0,0 +1,110 @@
+package html2html
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestImageOptimizer(t *testing.T) {
+	html := `<p><img src="/hero.jpg" alt="hero"></p><p><img src="/a.jpg" width="300"><img src="data:image/png;base64,AAAA" loading="eager"></p><picture><img src="/b.jpg" width="10" height="10"></picture>`
+
+	optimizer := NewImageOptimizer()
+	optimizer.EagerImages = 1
+	optimizer.URLTemplate = "{src}?w={width}&fm={format}"
+	optimizer.Widths = []int{640, 320}
+	optimizer.Sizes = "100vw"
+	optimizer.Formats = []*ImageFormat{ImageFormatAVIF, {Name: "webp", Type: "image/webp", URLTemplate: "/webp{src}?w={width}"}}
+	optimizer.Dimensions = func(src string) (int, int, error) {
+		if src == "/a.jpg" {
+			return 1200, 800, nil
+		}
+		if src == "/hero.jpg" {
+			return 1600, 900, nil
+		}
+		return 0, 0, nil
+	}
+
+	conv := NewConverter()
+	conv.(ConverterOptions).SetPipeline(NewPipeline(optimizer.Pass()))
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `<p><picture>` +
+		`<source type="image/avif" srcset="/hero.jpg?w=320&fm=avif 320w, /hero.jpg?w=640&fm=avif 640w" sizes="100vw">` +
+		`<source type="image/webp" srcset="/webp/hero.jpg?w=320 320w, /webp/hero.jpg?w=640 640w" sizes="100vw">` +
+		`<img src="/hero.jpg" alt="hero" decoding="async" width="1600" height="900" srcset="/hero.jpg?w=320 320w, /hero.jpg?w=640 640w" sizes="100vw">` +
+		`</picture></p><p><picture>` +
+		`<source type="image/avif" srcset="/a.jpg?w=320&fm=avif 320w, /a.jpg?w=640&fm=avif 640w" sizes="100vw">` +
+		`<source type="image/webp" srcset="/webp/a.jpg?w=320 320w, /webp/a.jpg?w=640 640w" sizes="100vw">` +
+		`<img src="/a.jpg" width="300" loading="lazy" decoding="async" height="200" srcset="/a.jpg?w=320 320w, /a.jpg?w=640 640w" sizes="100vw">` +
+		`</picture><img src="data:image/png;base64,AAAA" loading="eager" decoding="async"></p>` +
+		`<picture><img src="/b.jpg" width="10" height="10" loading="lazy" decoding="async" srcset="/b.jpg?w=320 320w, /b.jpg?w=640 640w" sizes="100vw"></picture>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestImageOptimizer_defaults(t *testing.T) {
+	root := mustParse(t, `<img src="/a.jpg" decoding="sync">`)
+	if err := NewImageOptimizer().Optimize(root); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBufferString("")
+	root.BuildHTML(buf)
+	expected := `<img src="/a.jpg" decoding="sync" loading="lazy">`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+}
+
+func TestImageOptimizer_dimensionError(t *testing.T) {
+	optimizer := NewImageOptimizer()
+	optimizer.Dimensions = func(src string) (int, int, error) {
+		return 0, 0, errors.New("not found")
+	}
+
+	err := optimizer.Optimize(mustParse(t, `<img src="/missing.jpg">`))
+	if err == nil || !strings.Contains(err.Error(), "/missing.jpg") {
+		t.Error("unexpected", err)
+	}
+}
+
+func TestImageOptimizer_srcsetURL(t *testing.T) {
+	optimizer := NewImageOptimizer()
+	optimizer.Lazy = false
+	optimizer.AsyncDecoding = false
+	optimizer.URLTemplate = "{src}?fm={format}&w={width}"
+	optimizer.Widths = []int{320}
+	optimizer.Formats = []*ImageFormat{ImageFormatWebP}
+
+	root := mustParse(t, `<img src="/my photo,1.jpg?v=2#top"><img src="/b.jpg">`)
+	if err := optimizer.Optimize(root); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBufferString("")
+	root.BuildHTML(buf)
+	expected := `<picture><source type="image/webp" srcset="/my%20photo%2C1.jpg?v=2&fm=webp&w=320#top 320w">` +
+		`<img src="/my photo,1.jpg?v=2#top" srcset="/my%20photo%2C1.jpg?v=2&w=320#top 320w"></picture>` +
+		`<picture><source type="image/webp" srcset="/b.jpg?fm=webp&w=320 320w"><img src="/b.jpg" srcset="/b.jpg?w=320 320w"></picture>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+
+	optimizer.FallbackURLTemplate = "/original{src}"
+	root = mustParse(t, `<img src="/b.jpg">`)
+	if err := optimizer.Optimize(root); err != nil {
+		t.Fatal(err)
+	}
+	if srcset := root.GetElementsByTagName("img")[0].GetAttr("srcset"); srcset == nil || srcset.Value != "/original/b.jpg 320w" {
+		t.Error("unexpected", srcset)
+	}
+}
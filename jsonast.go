@@ -0,0 +1,234 @@
+package html2html
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+var _ Serializer = &JSONASTSerializer{}
+
+// Position は Parse した元の文字列での位置。Offset はバイト数で0から、Line と Column は1から始まる
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// SourceRange は Token の元の文字列での範囲。要素は開始タグの始まりから終了タグの終わりまで
+type SourceRange struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// SourcePosition は ConverterOptions.SetRecordPositions(true) で Parse した token の位置を返す
+func SourcePosition(token Token) (SourceRange, bool) {
+	var position *SourceRange
+	switch impl := token.(type) {
+	case *tagImpl:
+		position = impl.position
+	case *textTokenImpl:
+		position = impl.position
+	}
+	if position == nil {
+		return SourceRange{}, false
+	}
+
+	return *position, true
+}
+
+// SetSourcePosition は token の位置を設定する
+func SetSourcePosition(token Token, position SourceRange) {
+	switch impl := token.(type) {
+	case *tagImpl:
+		impl.position = &position
+	case *textTokenImpl:
+		impl.position = &position
+	}
+}
+
+// JSON AST の type
+const (
+	JSONNodeRoot    = "root"
+	JSONNodeElement = "element"
+	JSONNodeText    = "text"
+	JSONNodeComment = "comment"
+	JSONNodeDoctype = "doctype"
+)
+
+// JSONNode は Tag の木を JSON で表したもの。
+//
+//	{"type": "root", "children": [...]}
+//	{"type": "element", "name": "a", "attrs": [{"name": "href", "value": "/"}], "selfClosing": false, "children": [...]}
+//	{"type": "text", "value": "Hello"}
+//	{"type": "comment", "value": " note "}
+//	{"type": "doctype", "value": "html"}
+//
+// 属性は順番と重複を保つため配列にする。文字列は Parse で文字参照を展開したもの。
+// position は位置を含めて書き出した場合だけあり、SourceRange の形をしている。
+type JSONNode struct {
+	Type        string       `json:"type"`
+	Name        string       `json:"name,omitempty"`
+	Attrs       []JSONAttr   `json:"attrs,omitempty"`
+	SelfClosing bool         `json:"selfClosing,omitempty"`
+	Value       string       `json:"value,omitempty"`
+	Children    []*JSONNode  `json:"children,omitempty"`
+	Position    *SourceRange `json:"position,omitempty"`
+}
+
+// JSONAttr は JSONNode の要素の属性
+type JSONAttr struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// NewJSONNode は token とその子孫を JSONNode にする。withPositions なら SourcePosition も含める
+func NewJSONNode(token Token, withPositions bool) *JSONNode {
+	node := &JSONNode{}
+	if withPositions {
+		if position, ok := SourcePosition(token); ok {
+			node.Position = &position
+		}
+	}
+
+	switch token.Type() {
+	case TypeTagToken:
+		tag := token.Tag()
+		if tag.IsDocumentRoot() {
+			node.Type = JSONNodeRoot
+		} else {
+			node.Type = JSONNodeElement
+			node.Name = tag.Name()
+			node.SelfClosing = tag.IsSelfClosing()
+			for _, attr := range tag.Attrs() {
+				node.Attrs = append(node.Attrs, JSONAttr{Name: attr.Key, Value: attr.Value})
+			}
+		}
+		for _, child := range tag.Tokens() {
+			node.Children = append(node.Children, NewJSONNode(child, withPositions))
+		}
+	case TypeTextToken:
+		node.Type = JSONNodeText
+		node.Value = token.TextToken().Text()
+	case TypeCommentToken:
+		node.Type = JSONNodeComment
+		node.Value = token.TextToken().Text()
+	case TypeDoctypeToken:
+		node.Type = JSONNodeDoctype
+		node.Value = token.TextToken().Text()
+	}
+
+	return node
+}
+
+// Token は node とその子孫から Token の木を作る。
+// HTML として出力できない要素名や属性名と、一番上以外の root はエラーにする
+func (node *JSONNode) Token() (Token, error) {
+	return node.token(true)
+}
+
+func (node *JSONNode) token(top bool) (Token, error) {
+	var token Token
+	switch node.Type {
+	case JSONNodeRoot, JSONNodeElement:
+		var tag Tag
+		switch {
+		case node.Type == JSONNodeRoot:
+			if !top {
+				return nil, fmt.Errorf("root node must be at the top")
+			}
+			tag = CreateDocumentRoot()
+		case node.Name == "":
+			return nil, fmt.Errorf("element without name")
+		case !tagNamePattern.MatchString(node.Name):
+			return nil, fmt.Errorf("invalid element name: %q", node.Name)
+		case node.SelfClosing:
+			tag = CreateElementSelfClosing(node.Name)
+		default:
+			tag = CreateElement(node.Name)
+		}
+		for _, attr := range node.Attrs {
+			if !isValidAttrName(attr.Name) {
+				return nil, fmt.Errorf("invalid attribute name: %q", attr.Name)
+			}
+			tag.AddAttr(attr.Name, attr.Value)
+		}
+		for _, child := range node.Children {
+			childToken, err := child.token(false)
+			if err != nil {
+				return nil, err
+			}
+			tag.AddChildTokens(childToken)
+		}
+		token = tag
+	case JSONNodeText:
+		token = CreateTextToken(node.Value)
+	case JSONNodeComment:
+		token = CreateCommentToken(node.Value)
+	case JSONNodeDoctype:
+		token = CreateDoctypeToken(node.Value)
+	default:
+		return nil, fmt.Errorf("unknown node type: %q", node.Type)
+	}
+
+	if node.Type != JSONNodeElement && node.Type != JSONNodeRoot && len(node.Children) != 0 {
+		return nil, fmt.Errorf("%s node can't have children", node.Type)
+	}
+	if node.Position != nil {
+		SetSourcePosition(token, *node.Position)
+	}
+
+	return token, nil
+}
+
+// isValidAttrName は name が HTML の属性名として書けるかを返す。空白、引用符、/ = > と制御文字は使えない
+func isValidAttrName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if r <= ' ' || r == 0x7f || strings.ContainsRune(`"'/=>`, r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MarshalJSONAST は token とその子孫を JSONNode の形の JSON にする。< > & はエスケープしない
+func MarshalJSONAST(token Token, withPositions bool) ([]byte, error) {
+	buf := bytes.NewBufferString("")
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(NewJSONNode(token, withPositions)); err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// UnmarshalJSONAST は MarshalJSONAST で作った JSON から Token の木を作る
+func UnmarshalJSONAST(data []byte) (Token, error) {
+	node := &JSONNode{}
+	if err := json.Unmarshal(data, node); err != nil {
+		return nil, err
+	}
+
+	return node.Token()
+}
+
+// JSONASTSerializer は MarshalJSONAST の JSON を出力する Serializer
+type JSONASTSerializer struct {
+	WithPositions bool
+}
+
+func (serializer *JSONASTSerializer) Serialize(buf *bytes.Buffer, token Token) error {
+	data, err := MarshalJSONAST(token, serializer.WithPositions)
+	if err != nil {
+		return err
+	}
+	buf.Write(data)
+
+	return nil
+}
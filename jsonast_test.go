@@ -0,0 +1,97 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestMarshalJSONAST(t *testing.T) {
+	html := `<!DOCTYPE html><p class="a" id=b>Hi &amp; bye<br/></p><!-- c -->`
+
+	conv := NewConverter()
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := MarshalJSONAST(root, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"type":"root","children":[{"type":"doctype","value":"html"},{"type":"element","name":"p","attrs":[{"name":"class","value":"a"},{"name":"id","value":"b"}],"children":[{"type":"text","value":"Hi & bye"},{"type":"element","name":"br","selfClosing":true}]},{"type":"comment","value":" c "}]}`
+	if string(data) != expected {
+		t.Log("expected:\n", expected, "actual:\n", string(data))
+		t.Fail()
+	}
+
+	token, err := UnmarshalJSONAST(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := bytes.NewBufferString("")
+	root.BuildHTML(before)
+	after := bytes.NewBufferString("")
+	token.BuildHTML(after)
+	if before.String() != after.String() {
+		t.Log("expected:\n", before.String(), "actual:\n", after.String())
+		t.Fail()
+	}
+}
+
+func TestMarshalJSONAST_positions(t *testing.T) {
+	html := "<div>\n  <p>Hi</p>\n</div>"
+
+	conv := NewConverter()
+	conv.(ConverterOptions).SetRecordPositions(true)
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := root.GetElementsByTagName("p")[0]
+	position, ok := SourcePosition(p)
+	if !ok {
+		t.Fatal("position is not recorded")
+	}
+	expected := SourceRange{Start: Position{Offset: 8, Line: 2, Column: 3}, End: Position{Offset: 17, Line: 2, Column: 12}}
+	if position != expected {
+		t.Error("unexpected", position)
+	}
+
+	data, err := MarshalJSONAST(p, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := UnmarshalJSONAST(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if position, _ := SourcePosition(token); position != expected {
+		t.Error("unexpected", position)
+	}
+	text := token.Tag().Tokens()[0]
+	if position, _ := SourcePosition(text); position.Start.Column != 6 || position.End.Column != 8 {
+		t.Error("unexpected", position)
+	}
+}
+
+func TestUnmarshalJSONAST_invalid(t *testing.T) {
+	invalids := []string{
+		`{"type":"unknown"}`,
+		`{"type":"element"}`,
+		`{"type":"text","value":"a","children":[{"type":"text"}]}`,
+		`{"type":`,
+		`{"type":"element","name":"img src=x onerror=alert(1)"}`,
+		`{"type":"element","name":"Img"}`,
+		`{"type":"element","name":"img","attrs":[{"name":"a\"><script>x()</script","value":"v"}]}`,
+		`{"type":"element","name":"img","attrs":[{"name":"","value":"v"}]}`,
+		`{"type":"element","name":"p","attrs":[{"name":"a=b","value":"v"}]}`,
+		`{"type":"root","children":[{"type":"element","name":"p","children":[{"type":"root"}]}]}`,
+	}
+	for _, invalid := range invalids {
+		if _, err := UnmarshalJSONAST([]byte(invalid)); err == nil {
+			t.Error("unexpected", invalid)
+		}
+	}
+}
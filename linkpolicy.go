@@ -0,0 +1,170 @@
+package html2html
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+var _ TokenConsumer = &linkPolicyConsumer{}
+
+// DefaultExternalLinkRel はユーザ投稿中の外部リンクに付ける rel
+var DefaultExternalLinkRel = []string{"nofollow", "ugc", "noopener"}
+
+// LinkPolicy は <a> を内部リンクと外部リンクに分類し、外部リンクに rel や target を付ける。
+// javascript: のリンクは中身ごと取り除く。
+type LinkPolicy struct {
+	// InternalHosts に含まれないホストへのリンクを外部リンクとする。"*.example.com" でサブドメインも含む
+	InternalHosts []string
+	Rel           []string
+	Target        string
+	// ExternalLinkIcon が設定されていれば外部リンクの末尾に追加する
+	ExternalLinkIcon func() Token
+	// UnwrapJavaScriptLinks が true なら javascript: のリンクは <a> だけを取り除いて中身を残す
+	UnwrapJavaScriptLinks bool
+}
+
+func NewLinkPolicy(internalHosts ...string) *LinkPolicy {
+	return &LinkPolicy{
+		InternalHosts: internalHosts,
+		Rel:           DefaultExternalLinkRel,
+		Target:        "_blank",
+	}
+}
+
+// NewLinkPolicyConsumer は <a> に LinkPolicy を適用するTokenConsumerを返す。"a" に設定して使う
+func NewLinkPolicyConsumer(conv Converter, policy *LinkPolicy) TokenConsumer {
+	return &linkPolicyConsumer{conv: conv, policy: policy}
+}
+
+type linkPolicyConsumer struct {
+	conv   Converter
+	policy *LinkPolicy
+}
+
+func (consumer *linkPolicyConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	tmp, token, err := consumeElement(consumer.conv, parent, tokenizer, token)
+	if err != nil && err != io.EOF {
+		return token, err
+	}
+
+	ApplyLinkPolicy(tmp, consumer.policy)
+	parent.AddChildTokens(tmp.Tokens()...)
+
+	return token, err
+}
+
+// ApplyLinkPolicy は tag 以下の全ての <a> に policy を適用する
+func ApplyLinkPolicy(tag Tag, policy *LinkPolicy) {
+	tokens := make([]Token, 0, len(tag.Tokens()))
+	changed := false
+	for _, token := range tag.Tokens() {
+		if token.Type() != TypeTagToken {
+			tokens = append(tokens, token)
+			continue
+		}
+
+		child := token.Tag()
+		ApplyLinkPolicy(child, policy)
+
+		if child.Name() == "a" {
+			if attr := child.GetAttr("href"); attr != nil && IsJavaScriptURL(attr.Value) {
+				if policy.UnwrapJavaScriptLinks {
+					tokens = append(tokens, child.Tokens()...)
+				}
+				changed = true
+				continue
+			}
+			policy.applyLink(child)
+		}
+		tokens = append(tokens, token)
+	}
+
+	if changed {
+		tag.SetTokens(tokens)
+	}
+}
+
+// IsExternal は href が外部へのリンクかを返す。相対URLや http(s) 以外のスキームは外部リンクとしない
+func (policy *LinkPolicy) IsExternal(href string) bool {
+	u, err := url.Parse(strings.TrimSpace(href))
+	if err != nil {
+		return false
+	}
+	if u.Scheme != "" && u.Scheme != "http" && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host == "" {
+		return false
+	}
+	for _, internalHost := range policy.InternalHosts {
+		internalHost = strings.ToLower(internalHost)
+		if strings.HasPrefix(internalHost, "*.") {
+			if host == internalHost[2:] || strings.HasSuffix(host, internalHost[1:]) {
+				return false
+			}
+		} else if host == internalHost {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (policy *LinkPolicy) applyLink(tag Tag) {
+	attr := tag.GetAttr("href")
+	if attr == nil || !policy.IsExternal(attr.Value) {
+		return
+	}
+
+	if len(policy.Rel) != 0 {
+		MergeAttrTokens(tag, "rel", policy.Rel...)
+	}
+	if policy.Target != "" {
+		tag.RemoveAttr("target")
+		tag.AddAttr("target", policy.Target)
+	}
+	if policy.ExternalLinkIcon != nil {
+		tag.AddChildTokens(policy.ExternalLinkIcon())
+	}
+}
+
+// IsJavaScriptURL は rawURL が javascript: スキームかを返す。ブラウザ同様に前後の空白や制御文字は無視する
+func IsJavaScriptURL(rawURL string) bool {
+	cleaned := strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, rawURL)
+
+	return strings.HasPrefix(strings.ToLower(cleaned), "javascript:")
+}
+
+// MergeAttrTokens は rel や class のような空白区切りの属性に、重複しないように値を追加する
+func MergeAttrTokens(tag Tag, attrKey string, values ...string) {
+	attr := tag.GetAttr(attrKey)
+	if attr == nil {
+		tag.AddAttr(attrKey, "")
+		attr = tag.GetAttr(attrKey)
+	}
+
+	current := strings.Fields(attr.Value)
+	for _, value := range values {
+		found := false
+		for _, v := range current {
+			if strings.EqualFold(v, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			current = append(current, value)
+		}
+	}
+	attr.Value = strings.Join(current, " ")
+}
@@ -0,0 +1,60 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLinkPolicyConsumer(t *testing.T) {
+	html := `<p><a href="https://favclip.com/about">about</a> <a href="https://example.com/" rel="author nofollow">ext</a> <a href=" javascript:alert(1)">click <b>me</b></a></p>`
+
+	policy := NewLinkPolicy("favclip.com", "*.favclip.com")
+	policy.ExternalLinkIcon = func() Token {
+		icon := CreateElement("span")
+		icon.AddAttr("class", "external")
+		return icon
+	}
+
+	conv := NewConverter()
+	conv.SetTagNameConsumer("a", NewLinkPolicyConsumer(conv, policy))
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p><a href="https://favclip.com/about">about</a> <a href="https://example.com/" rel="author nofollow ugc noopener" target="_blank">ext<span class="external"></span></a> </p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+
+	policy.UnwrapJavaScriptLinks = true
+	result, err = conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = `<p><a href="https://favclip.com/about">about</a> <a href="https://example.com/" rel="author nofollow ugc noopener" target="_blank">ext<span class="external"></span></a> click <b>me</b></p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestLinkPolicy_IsExternal(t *testing.T) {
+	policy := NewLinkPolicy("favclip.com", "*.favclip.com")
+
+	for href, expected := range map[string]bool{
+		"/foo":                     false,
+		"#top":                     false,
+		"mailto:info@example.com":  false,
+		"https://favclip.com/":     false,
+		"https://www.favclip.com/": false,
+		"//example.com/":           true,
+		"http://example.com/":      true,
+		"https://favclip.com.evil": true,
+	} {
+		if v := policy.IsExternal(href); v != expected {
+			t.Error("unexpected", href, v)
+		}
+	}
+}
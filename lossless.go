@@ -0,0 +1,94 @@
+package html2html
+
+import (
+	"golang.org/x/net/html"
+)
+
+// tagSource は Lossless で Parse した要素の開始タグと終了タグの元の文字列と、その時の名前と属性
+type tagSource struct {
+	start []byte
+	end   []byte
+	name  string
+	attrs []html.Attribute
+	// values は属性名ごとの、文字参照を展開していない値
+	values map[string]string
+}
+
+// matches は tag の名前と属性が Parse した時から変わっていないかを返す
+func (source *tagSource) matches(tag Tag) bool {
+	if source == nil || source.start == nil || source.name != tag.Name() || len(source.attrs) != len(tag.Attrs()) {
+		return false
+	}
+	for idx, attr := range tag.Attrs() {
+		if source.attrs[idx].Key != attr.Key || source.attrs[idx].Val != attr.Value {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesEnd は終了タグを元の文字列のまま出力できるかを返す
+func (source *tagSource) matchesEnd(tag Tag) bool {
+	return source != nil && source.end != nil && source.name == tag.Name()
+}
+
+func setTagSource(tag Tag, raw []byte, token html.Token) {
+	impl, ok := tag.(*tagImpl)
+	if !ok || raw == nil {
+		return
+	}
+
+	_, formats := scanRawAttrs(raw)
+	values := make(map[string]string, len(formats))
+	for key, format := range formats {
+		values[key] = format.Value
+	}
+
+	impl.source = &tagSource{
+		start:  raw,
+		name:   token.Data,
+		attrs:  append([]html.Attribute(nil), token.Attr...),
+		values: values,
+	}
+}
+
+// rawAttrValue は attr の値が Parse した時から変わっていなければ、文字参照を展開していない元の値を返す
+func rawAttrValue(tag Tag, attr *Attr) (string, bool) {
+	impl, ok := tag.(*tagImpl)
+	if !ok || impl.source == nil {
+		return "", false
+	}
+
+	for _, parsed := range impl.source.attrs {
+		if parsed.Key != attr.Key {
+			continue
+		}
+		if parsed.Val != attr.Value {
+			return "", false
+		}
+		raw, ok := impl.source.values[attr.Key]
+		return raw, ok
+	}
+
+	return "", false
+}
+
+func setEndTagSource(tag Tag, raw []byte) {
+	impl, ok := tag.(*tagImpl)
+	if !ok || impl.source == nil {
+		return
+	}
+
+	impl.source.end = raw
+}
+
+func setTextSource(token Token, raw []byte, text string) {
+	impl, ok := token.(*textTokenImpl)
+	if !ok || raw == nil {
+		return
+	}
+
+	impl.source = raw
+	impl.sourceText = text
+}
@@ -0,0 +1,67 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestConverter_lossless(t *testing.T) {
+	html := `<!doctype html>
+<DIV Class='a'  id=main>Caf&eacute; &amp; tea&nbsp;<BR/>
+  <A HREF="/x?a=1&amp;b=2" >link</A ><!--  note  --></DIV >`
+
+	conv := NewConverter()
+	conv.(ConverterOptions).SetLossless(true)
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != html {
+		t.Log("expected:\n", html, "actual:\n", result)
+		t.Fail()
+	}
+
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := root.GetElementsByTagName("a")[0]
+	a.GetAttr("href").Value = "/y"
+	a.Parent().AddText("!")
+
+	buf := bytes.NewBufferString("")
+	root.BuildHTMLWithOptions(buf, &BuildOptions{Lossless: true})
+	expected := `<!doctype html>
+<DIV Class='a'  id=main>Caf&eacute; &amp; tea&nbsp;<BR/>
+  <a href="/y">link</A ><!--  note  -->!</DIV >`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+
+	// 元の文字列を覚えていなければ変わらない
+	buf.Reset()
+	root.BuildHTML(buf)
+	expected = `<!DOCTYPE html>
+<div class="a" id="main">Café & tea` + "\u00a0" + `<br/>
+  <a href="/y">link</a><!--  note  -->!</div>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+}
+
+func TestConverter_losslessDisabled(t *testing.T) {
+	conv := NewConverter()
+	result, err := conv.Convert(strings.NewReader(`<P Class='a'>x</P >`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<p class="a">x</p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
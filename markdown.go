@@ -0,0 +1,659 @@
+package html2html
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	mdATXHeadingPattern     = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*))?$`)
+	mdATXClosingPattern     = regexp.MustCompile(`(?:^|[ \t]+)#+[ \t]*$`)
+	mdThematicBreakPattern  = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	mdSetextPattern         = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	mdFencePattern          = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})(.*)$")
+	mdBlockquotePattern     = regexp.MustCompile(`^ {0,3}> ?`)
+	mdListMarkerPattern     = regexp.MustCompile(`^( {0,3})([-+*]|\d{1,9}[.)])([ \t]+|$)`)
+	mdHTMLBlockPattern      = regexp.MustCompile(`^ {0,3}<(?:[A-Za-z][A-Za-z0-9-]*(?:[\s/>]|$)|/[A-Za-z]|!--|![A-Za-z]|\?)`)
+	mdTableDelimiterPattern = regexp.MustCompile(`^ {0,3}\|?[ \t]*:?-+:?[ \t]*(?:\|[ \t]*:?-+:?[ \t]*)*\|?[ \t]*$`)
+	mdTaskPattern           = regexp.MustCompile(`^\[([ xX])\](?:[ \t]+|$)`)
+	mdLinkDefinitionPattern = regexp.MustCompile(`^ {0,3}\[((?:[^\]\\]|\\.)+)\]:[ \t]*(<[^<>\n]*>|\S+)(?:[ \t]+("(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'|\((?:[^()\\]|\\.)*\)))?[ \t]*$`)
+)
+
+// MarkdownParser は Markdown (CommonMark と、GFM の表、打ち消し線、タスクリスト)から Tag の木を作る。
+// 文字列は Converter.Parse と同じく文字参照を展開して持つので、Pipeline の Pass と Serializer をそのまま使える。
+// TokenConsumer は html.Tokenizer を読むので木には使えない。ApplyLinkPolicy のような木に対する処理を Pass にするか、
+// TokenConsumer を通す必要があれば BuildHTML した文字列を Converter.Parse で読み直すこと。
+type MarkdownParser struct {
+	// HTML が設定されていれば HTML ブロックをこれで Parse する。nil なら HTML ブロックも文字列として扱う。
+	// 文中の HTML は常に文字列として扱う。
+	HTML Converter
+}
+
+func NewMarkdownParser() *MarkdownParser {
+	return &MarkdownParser{}
+}
+
+// Parse は r の Markdown を読んで document root を返す
+func (parser *MarkdownParser) Parse(r io.Reader) (Tag, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &markdownState{parser: parser, refs: make(map[string]markdownRef)}
+	root := CreateDocumentRoot()
+	if err := state.blocks(root, splitMarkdownLines(string(b))); err != nil {
+		return nil, err
+	}
+	state.finish()
+
+	return root, nil
+}
+
+// Convert は r の Markdown を Parse し、conv の Pipeline と Serializer で文字列にする。conv の TokenConsumer は使わない。
+// Serializer が無ければ文字列と属性値の & < > " を文字参照にして出力する。
+func (parser *MarkdownParser) Convert(conv Converter, r io.Reader) (string, error) {
+	root, err := parser.Parse(r)
+	if err != nil {
+		return "", err
+	}
+	opts, _ := conv.(ConverterOptions)
+	if opts != nil && opts.Pipeline() != nil {
+		if _, err := opts.Pipeline().Run(root); err != nil {
+			return "", err
+		}
+	}
+
+	buf := bytes.NewBufferString("")
+	if opts != nil && opts.Serializer() != nil {
+		if err := opts.Serializer().Serialize(buf, root); err != nil {
+			return "", err
+		}
+	} else {
+		root.BuildHTMLWithOptions(buf, &BuildOptions{Entities: EntityMinimal})
+	}
+
+	return buf.String(), nil
+}
+
+type markdownRef struct {
+	href  string
+	title string
+}
+
+// markdownPending は文中の書式を後で解釈する段落など。リンクの参照定義を全て読んでから解釈する
+type markdownPending struct {
+	tag  Tag
+	text string
+}
+
+// markdownItem は文中の書式を解釈した後で仕上げるリストの項目
+type markdownItem struct {
+	li      Tag
+	tight   bool
+	task    bool
+	checked bool
+}
+
+type markdownState struct {
+	parser  *MarkdownParser
+	refs    map[string]markdownRef
+	pending []markdownPending
+	items   []markdownItem
+}
+
+func (state *markdownState) finish() {
+	for _, pending := range state.pending {
+		pending.tag.AddChildTokens(state.inline(pending.text)...)
+	}
+
+	for _, item := range state.items {
+		if item.task {
+			target := item.li
+			if tokens := item.li.Tokens(); len(tokens) != 0 && tokens[0].Type() == TypeTagToken && tokens[0].Tag().Name() == "p" {
+				target = tokens[0].Tag()
+			}
+			checkbox := CreateElement("input")
+			checkbox.AddAttr("type", "checkbox")
+			checkbox.AddAttr("disabled", "")
+			if item.checked {
+				checkbox.AddAttr("checked", "")
+			}
+			target.UnshiftChileToken(CreateTextToken(" "))
+			target.UnshiftChileToken(checkbox)
+		}
+
+		if item.tight {
+			// 詰めたリストの段落は p で囲まない
+			var tokens []Token
+			for _, token := range item.li.Tokens() {
+				if token.Type() == TypeTagToken && token.Tag().Name() == "p" {
+					tokens = append(tokens, token.Tag().Tokens()...)
+				} else {
+					tokens = append(tokens, token)
+				}
+			}
+			item.li.SetTokens(tokens)
+		}
+	}
+}
+
+// blocks は lines をブロックに分けて parent に追加する
+func (state *markdownState) blocks(parent Tag, lines []string) error {
+	for i := 0; i < len(lines); {
+		line := lines[i]
+		var err error
+		switch {
+		case isBlankLine(line):
+			i++
+		case mdFencePattern.MatchString(line) && isFenceStart(line):
+			i = state.fencedCode(parent, lines, i)
+		case mdATXHeadingPattern.MatchString(line):
+			state.atxHeading(parent, line)
+			i++
+		case mdThematicBreakPattern.MatchString(line):
+			parent.AddChildTokens(CreateElement("hr"))
+			i++
+		case mdBlockquotePattern.MatchString(line):
+			i, err = state.blockquote(parent, lines, i)
+		case mdListMarkerPattern.MatchString(line):
+			i, err = state.list(parent, lines, i)
+		case leadingSpaces(line) >= 4:
+			i = state.indentedCode(parent, lines, i)
+		case mdHTMLBlockPattern.MatchString(line):
+			i, err = state.htmlBlock(parent, lines, i)
+		case i+1 < len(lines) && isTableStart(line, lines[i+1]):
+			i = state.table(parent, lines, i)
+		default:
+			i = state.paragraph(parent, lines, i)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (state *markdownState) atxHeading(parent Tag, line string) {
+	m := mdATXHeadingPattern.FindStringSubmatch(line)
+	text := mdATXClosingPattern.ReplaceAllString(m[2], "")
+	if strings.Trim(m[2], "# \t") == "" {
+		text = ""
+	}
+
+	heading := CreateElement("h" + strconv.Itoa(len(m[1])))
+	parent.AddChildTokens(heading)
+	state.pending = append(state.pending, markdownPending{tag: heading, text: strings.TrimSpace(text)})
+}
+
+func (state *markdownState) fencedCode(parent Tag, lines []string, i int) int {
+	m := mdFencePattern.FindStringSubmatch(lines[i])
+	indent, fence, info := len(m[1]), m[2], strings.TrimSpace(m[3])
+	closing := regexp.MustCompile(`^ {0,3}` + regexp.QuoteMeta(fence[:1]) + `{` + strconv.Itoa(len(fence)) + `,}[ \t]*$`)
+
+	var content []string
+	for i++; i < len(lines); i++ {
+		if closing.MatchString(lines[i]) {
+			i++
+			break
+		}
+		line := lines[i]
+		if n := leadingSpaces(line); n < indent {
+			line = line[n:]
+		} else {
+			line = line[indent:]
+		}
+		content = append(content, line)
+	}
+
+	code := CreateElement("code")
+	if info != "" {
+		language := unescapeMarkdown(strings.Fields(info)[0])
+		code.AddAttr("class", "language-"+language)
+	}
+	if len(content) != 0 {
+		code.AddText(strings.Join(content, "\n") + "\n")
+	}
+	pre := CreateElement("pre")
+	pre.AddChildTokens(code)
+	parent.AddChildTokens(pre)
+
+	return i
+}
+
+func (state *markdownState) indentedCode(parent Tag, lines []string, i int) int {
+	var content []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) {
+			content = append(content, "")
+			continue
+		}
+		if leadingSpaces(line) < 4 {
+			break
+		}
+		content = append(content, line[4:])
+	}
+	for len(content) != 0 && content[len(content)-1] == "" {
+		content = content[:len(content)-1]
+	}
+
+	code := CreateElement("code")
+	code.AddText(strings.Join(content, "\n") + "\n")
+	pre := CreateElement("pre")
+	pre.AddChildTokens(code)
+	parent.AddChildTokens(pre)
+
+	return i
+}
+
+func (state *markdownState) blockquote(parent Tag, lines []string, i int) (int, error) {
+	var content []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if loc := mdBlockquotePattern.FindStringIndex(line); loc != nil {
+			content = append(content, line[loc[1]:])
+			continue
+		}
+		// 段落の続きは > を省略できる
+		if isBlankLine(line) || len(content) == 0 || isBlankLine(content[len(content)-1]) || startsMarkdownBlock(line) {
+			break
+		}
+		content = append(content, line)
+	}
+
+	blockquote := CreateElement("blockquote")
+	parent.AddChildTokens(blockquote)
+
+	return i, state.blocks(blockquote, content)
+}
+
+// markdownListMarker はリストの項目の始まり
+type markdownListMarker struct {
+	// kind は - + * か、番号付きリストの . )
+	kind    byte
+	ordered bool
+	start   int
+	// offset は項目の中身が始まる列
+	offset  int
+	content string
+}
+
+func parseListMarker(line string) (*markdownListMarker, bool) {
+	m := mdListMarkerPattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	marker := &markdownListMarker{kind: m[2][len(m[2])-1]}
+	if marker.kind == '.' || marker.kind == ')' {
+		marker.ordered = true
+		marker.start, _ = strconv.Atoi(m[2][:len(m[2])-1])
+	}
+
+	rest := line[len(m[0]):]
+	spaces := len(m[3])
+	switch {
+	case rest == "" && isBlankLine(m[3]):
+		// 空の項目
+		marker.offset = len(m[1]) + len(m[2]) + 1
+	case spaces > 4:
+		// 5つ以上の空白は字下げしたコードになる
+		marker.offset = len(m[1]) + len(m[2]) + 1
+		marker.content = strings.Repeat(" ", spaces-1) + rest
+	default:
+		marker.offset = len(m[0])
+		marker.content = rest
+	}
+
+	return marker, true
+}
+
+func (state *markdownState) list(parent Tag, lines []string, i int) (int, error) {
+	first, _ := parseListMarker(lines[i])
+	var list Tag
+	if first.ordered {
+		list = CreateElement("ol")
+		if first.start != 1 {
+			list.AddAttr("start", strconv.Itoa(first.start))
+		}
+	} else {
+		list = CreateElement("ul")
+	}
+	parent.AddChildTokens(list)
+
+	loose := false
+	var items [][]string
+	for i < len(lines) {
+		marker, ok := parseListMarker(lines[i])
+		if !ok || marker.kind != first.kind || mdThematicBreakPattern.MatchString(lines[i]) {
+			break
+		}
+
+		content := []string{marker.content}
+		for i++; i < len(lines); i++ {
+			line := lines[i]
+			switch {
+			case isBlankLine(line):
+				content = append(content, "")
+				continue
+			case leadingSpaces(line) >= marker.offset:
+				content = append(content, line[marker.offset:])
+				continue
+			case content[len(content)-1] != "" && !startsMarkdownBlock(line) && !mdListMarkerPattern.MatchString(line):
+				// 段落の続きは字下げを省略できる
+				content = append(content, strings.TrimLeft(line, " "))
+				continue
+			}
+			break
+		}
+
+		trailing := 0
+		for len(content) > 1 && content[len(content)-1] == "" {
+			content = content[:len(content)-1]
+			trailing++
+		}
+		if next, ok := parseNextListMarker(lines, i); ok && next.kind == first.kind && trailing > 0 {
+			loose = true
+		}
+		if hasBlankBetweenBlocks(content) {
+			loose = true
+		}
+		items = append(items, content)
+	}
+
+	for _, content := range items {
+		li := CreateElement("li")
+		list.AddChildTokens(li)
+
+		item := markdownItem{li: li, tight: !loose}
+		if m := mdTaskPattern.FindStringSubmatch(content[0]); m != nil {
+			item.task = true
+			item.checked = m[1] != " "
+			content[0] = content[0][len(m[0]):]
+		}
+		state.items = append(state.items, item)
+
+		if err := state.blocks(li, content); err != nil {
+			return i, err
+		}
+	}
+
+	return i, nil
+}
+
+func parseNextListMarker(lines []string, i int) (*markdownListMarker, bool) {
+	if i >= len(lines) {
+		return nil, false
+	}
+
+	return parseListMarker(lines[i])
+}
+
+// hasBlankBetweenBlocks は項目の中のブロックの間に空行があるかを返す。コードの中の空行は数えない
+func hasBlankBetweenBlocks(lines []string) bool {
+	var fence string
+	blank := false
+	for _, line := range lines {
+		if m := mdFencePattern.FindStringSubmatch(line); m != nil {
+			if fence == "" {
+				fence = m[2][:1]
+			} else if strings.HasPrefix(strings.TrimSpace(line), fence) {
+				fence = ""
+			}
+		}
+		if fence != "" {
+			continue
+		}
+		if isBlankLine(line) {
+			blank = true
+		} else if blank {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (state *markdownState) htmlBlock(parent Tag, lines []string, i int) (int, error) {
+	start := i
+	for i < len(lines) && !isBlankLine(lines[i]) {
+		i++
+	}
+	source := strings.Join(lines[start:i], "\n")
+
+	if state.parser.HTML == nil {
+		p := CreateElement("p")
+		p.AddText(source)
+		parent.AddChildTokens(p)
+		return i, nil
+	}
+
+	root, err := state.parser.HTML.Parse(strings.NewReader(source))
+	if err != nil {
+		return i, err
+	}
+	parent.AddChildTokens(root.Tokens()...)
+
+	return i, nil
+}
+
+func isTableStart(header string, delimiter string) bool {
+	if !strings.Contains(header, "|") || !mdTableDelimiterPattern.MatchString(delimiter) {
+		return false
+	}
+
+	return len(splitTableRow(header)) == len(splitTableRow(delimiter))
+}
+
+func (state *markdownState) table(parent Tag, lines []string, i int) int {
+	header := splitTableRow(lines[i])
+	var aligns []string
+	for _, cell := range splitTableRow(lines[i+1]) {
+		cell = strings.TrimSpace(cell)
+		switch {
+		case strings.HasPrefix(cell, ":") && strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "center")
+		case strings.HasPrefix(cell, ":"):
+			aligns = append(aligns, "left")
+		case strings.HasSuffix(cell, ":"):
+			aligns = append(aligns, "right")
+		default:
+			aligns = append(aligns, "")
+		}
+	}
+
+	table := CreateElement("table")
+	parent.AddChildTokens(table)
+	thead := CreateElement("thead")
+	table.AddChildTokens(thead)
+	thead.AddChildTokens(state.tableRow("th", header, aligns))
+
+	var tbody Tag
+	for i += 2; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) || startsMarkdownBlock(line) {
+			break
+		}
+		if tbody == nil {
+			tbody = CreateElement("tbody")
+			table.AddChildTokens(tbody)
+		}
+		tbody.AddChildTokens(state.tableRow("td", splitTableRow(line), aligns))
+	}
+
+	return i
+}
+
+func (state *markdownState) tableRow(cellName string, cells []string, aligns []string) Tag {
+	tr := CreateElement("tr")
+	for idx, align := range aligns {
+		cell := CreateElement(cellName)
+		if align != "" {
+			cell.AddAttr("align", align)
+		}
+		if idx < len(cells) {
+			state.pending = append(state.pending, markdownPending{tag: cell, text: strings.TrimSpace(cells[idx])})
+		}
+		tr.AddChildTokens(cell)
+	}
+
+	return tr
+}
+
+// splitTableRow は表の行を | で分ける。\| とコードの中の | では分けない
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	cell := bytes.NewBufferString("")
+	code := false
+	for idx := 0; idx < len(line); idx++ {
+		c := line[idx]
+		switch {
+		case c == '\\' && idx+1 < len(line) && line[idx+1] == '|':
+			cell.WriteByte('|')
+			idx++
+			continue
+		case c == '`':
+			code = !code
+		case c == '|' && !code:
+			cells = append(cells, cell.String())
+			cell.Reset()
+			continue
+		}
+		cell.WriteByte(c)
+	}
+
+	return append(cells, cell.String())
+}
+
+func (state *markdownState) paragraph(parent Tag, lines []string, i int) int {
+	var content []string
+	for ; i < len(lines) && !isBlankLine(lines[i]); i++ {
+		line := lines[i]
+		if len(content) != 0 {
+			if m := mdSetextPattern.FindStringSubmatch(line); m != nil && !state.onlyDefinitions(content) {
+				level := "h2"
+				if m[1][0] == '=' {
+					level = "h1"
+				}
+				state.addParagraph(parent, content, level)
+				return i + 1
+			}
+			if startsMarkdownBlock(line) {
+				break
+			}
+		}
+		content = append(content, strings.TrimLeft(line, " \t"))
+	}
+
+	state.addParagraph(parent, content, "p")
+	return i
+}
+
+// addParagraph は先頭のリンクの参照定義を読み、残りを name の要素にする
+func (state *markdownState) addParagraph(parent Tag, content []string, name string) {
+	for len(content) != 0 {
+		m := mdLinkDefinitionPattern.FindStringSubmatch(content[0])
+		if m == nil {
+			break
+		}
+		label := normalizeMarkdownLabel(m[1])
+		if _, ok := state.refs[label]; !ok {
+			href := strings.TrimSuffix(strings.TrimPrefix(m[2], "<"), ">")
+			title := ""
+			if len(m[3]) >= 2 {
+				title = m[3][1 : len(m[3])-1]
+			}
+			state.refs[label] = markdownRef{href: unescapeMarkdown(href), title: unescapeMarkdown(title)}
+		}
+		content = content[1:]
+	}
+	if len(content) == 0 {
+		return
+	}
+
+	tag := CreateElement(name)
+	parent.AddChildTokens(tag)
+	text := strings.Join(content, "\n")
+	state.pending = append(state.pending, markdownPending{tag: tag, text: strings.TrimRight(text, " \t")})
+}
+
+func (state *markdownState) onlyDefinitions(content []string) bool {
+	for _, line := range content {
+		if !mdLinkDefinitionPattern.MatchString(line) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// startsMarkdownBlock は line が段落を終わらせるブロックの始まりかを返す
+func startsMarkdownBlock(line string) bool {
+	if mdATXHeadingPattern.MatchString(line) || mdThematicBreakPattern.MatchString(line) ||
+		mdBlockquotePattern.MatchString(line) || mdHTMLBlockPattern.MatchString(line) ||
+		(mdFencePattern.MatchString(line) && isFenceStart(line)) {
+		return true
+	}
+
+	// 段落を終わらせられるのは、中身があり、番号付きなら1から始まるリストだけ
+	if marker, ok := parseListMarker(line); ok && strings.TrimSpace(marker.content) != "" {
+		return !marker.ordered || marker.start == 1
+	}
+
+	return false
+}
+
+// isFenceStart は ``` の情報文字列に ` が含まれていないかを返す
+func isFenceStart(line string) bool {
+	m := mdFencePattern.FindStringSubmatch(line)
+	return m != nil && !(m[2][0] == '`' && strings.Contains(m[3], "`"))
+}
+
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// splitMarkdownLines は改行を揃えて行に分け、行頭のタブを4桁ごとの空白にする
+func splitMarkdownLines(text string) []string {
+	text = strings.Replace(text, "\r\n", "\n", -1)
+	text = strings.Replace(text, "\r", "\n", -1)
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.Split(text, "\n")
+	for idx, line := range lines {
+		column := 0
+		expanded := bytes.NewBufferString("")
+		pos := 0
+		for ; pos < len(line) && (line[pos] == ' ' || line[pos] == '\t'); pos++ {
+			if line[pos] == '\t' {
+				expanded.WriteString(strings.Repeat(" ", 4-column%4))
+				column += 4 - column%4
+			} else {
+				expanded.WriteByte(' ')
+				column++
+			}
+		}
+		lines[idx] = expanded.String() + line[pos:]
+	}
+
+	return lines
+}
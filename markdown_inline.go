@@ -0,0 +1,455 @@
+package html2html
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+var (
+	mdEntityPattern        = regexp.MustCompile(`^&(?:#[xX][0-9a-fA-F]{1,6}|#[0-9]{1,7}|[A-Za-z][A-Za-z0-9]{1,31});`)
+	mdURIAutolinkPattern   = regexp.MustCompile(`^<([A-Za-z][A-Za-z0-9+.\-]{1,31}:[^\s<>]*)>`)
+	mdEmailAutolinkPattern = regexp.MustCompile(`^<([A-Za-z0-9.!#$%&'*+/=?^_` + "`" + `{|}~\-]+@[A-Za-z0-9](?:[A-Za-z0-9\-]{0,61}[A-Za-z0-9])?(?:\.[A-Za-z0-9](?:[A-Za-z0-9\-]{0,61}[A-Za-z0-9])?)*)>`)
+	mdEscapable            = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+)
+
+// markdownInline は文中の書式を解釈している途中の1つ。delim があれば強調の区切りの候補
+type markdownInline struct {
+	token     Token
+	delim     byte
+	count     int
+	origCount int
+	canOpen   bool
+	canClose  bool
+}
+
+// inline は text の文中の書式を解釈して Token にする
+func (state *markdownState) inline(text string) []Token {
+	var nodes []*markdownInline
+	buf := bytes.NewBufferString("")
+	flush := func() {
+		if buf.Len() != 0 {
+			nodes = append(nodes, &markdownInline{token: CreateTextToken(buf.String())})
+			buf.Reset()
+		}
+	}
+	add := func(token Token) {
+		flush()
+		nodes = append(nodes, &markdownInline{token: token})
+	}
+
+	for pos := 0; pos < len(text); {
+		c := text[pos]
+		switch c {
+		case '\\':
+			if pos+1 < len(text) && text[pos+1] == '\n' {
+				add(CreateElement("br"))
+				pos = skipLineIndent(text, pos+2)
+				continue
+			}
+			if pos+1 < len(text) && strings.IndexByte(mdEscapable, text[pos+1]) >= 0 {
+				buf.WriteByte(text[pos+1])
+				pos += 2
+				continue
+			}
+
+		case '\n':
+			// 行末の2つ以上の空白は改行になる
+			line := buf.String()
+			trimmed := strings.TrimRight(line, " ")
+			buf.Reset()
+			buf.WriteString(trimmed)
+			if len(line)-len(trimmed) >= 2 {
+				add(CreateElement("br"))
+			} else {
+				buf.WriteByte('\n')
+			}
+			pos = skipLineIndent(text, pos+1)
+			continue
+
+		case '`':
+			if end, code, ok := scanCodeSpan(text, pos); ok {
+				element := CreateElement("code")
+				element.AddText(code)
+				add(element)
+				pos = end
+				continue
+			}
+			run := len(text[pos:]) - len(strings.TrimLeft(text[pos:], "`"))
+			buf.WriteString(text[pos : pos+run])
+			pos += run
+			continue
+
+		case '*', '_', '~':
+			run := len(text[pos:]) - len(strings.TrimLeft(text[pos:], string(c)))
+			flush()
+			nodes = append(nodes, newDelimiterRun(text, pos, run))
+			pos += run
+			continue
+
+		case '!', '[':
+			image := c == '!'
+			if image && (pos+1 >= len(text) || text[pos+1] != '[') {
+				break
+			}
+			start := pos
+			if image {
+				start++
+			}
+			if token, end, ok := state.link(text, start, image); ok {
+				add(token)
+				pos = end
+				continue
+			}
+
+		case '<':
+			if m := mdURIAutolinkPattern.FindStringSubmatch(text[pos:]); m != nil {
+				add(markdownAutolink(m[1], m[1]))
+				pos += len(m[0])
+				continue
+			}
+			if m := mdEmailAutolinkPattern.FindStringSubmatch(text[pos:]); m != nil {
+				add(markdownAutolink("mailto:"+m[1], m[1]))
+				pos += len(m[0])
+				continue
+			}
+
+		case '&':
+			if m := mdEntityPattern.FindString(text[pos:]); m != "" {
+				if decoded := html.UnescapeString(m); decoded != m {
+					buf.WriteString(decoded)
+					pos += len(m)
+					continue
+				}
+			}
+		}
+
+		buf.WriteByte(c)
+		pos++
+	}
+	flush()
+
+	return markdownTokens(processEmphasis(nodes))
+}
+
+// newDelimiterRun は text[pos:pos+run] の * _ ~ の連続が強調を始められるか、終えられるかを調べる
+func newDelimiterRun(text string, pos int, run int) *markdownInline {
+	before, after := ' ', ' '
+	if pos > 0 {
+		before, _ = utf8.DecodeLastRuneInString(text[:pos])
+	}
+	if pos+run < len(text) {
+		after, _ = utf8.DecodeRuneInString(text[pos+run:])
+	}
+
+	isPunct := func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	}
+	leftFlanking := !unicode.IsSpace(after) && (!isPunct(after) || unicode.IsSpace(before) || isPunct(before))
+	rightFlanking := !unicode.IsSpace(before) && (!isPunct(before) || unicode.IsSpace(after) || isPunct(after))
+
+	c := text[pos]
+	node := &markdownInline{
+		token:     CreateTextToken(text[pos : pos+run]),
+		delim:     c,
+		count:     run,
+		origCount: run,
+		canOpen:   leftFlanking,
+		canClose:  rightFlanking,
+	}
+	if c == '_' {
+		// 単語の中の _ は強調にしない
+		node.canOpen = leftFlanking && (!rightFlanking || isPunct(before))
+		node.canClose = rightFlanking && (!leftFlanking || isPunct(after))
+	}
+
+	return node
+}
+
+// processEmphasis は区切りの組を見つけて em, strong, del で囲む
+func processEmphasis(nodes []*markdownInline) []*markdownInline {
+	for closer := 0; closer < len(nodes); closer++ {
+		c := nodes[closer]
+		if c.delim == 0 || !c.canClose || c.count == 0 {
+			continue
+		}
+
+		opener := -1
+		for j := closer - 1; j >= 0; j-- {
+			o := nodes[j]
+			if o.delim != c.delim || !o.canOpen || o.count == 0 {
+				continue
+			}
+			if c.delim == '~' {
+				if o.count >= 2 && c.count >= 2 {
+					opener = j
+					break
+				}
+				continue
+			}
+			// 3の倍数の規則
+			if (o.canClose || c.canOpen) && (o.origCount+c.origCount)%3 == 0 && !(o.origCount%3 == 0 && c.origCount%3 == 0) {
+				continue
+			}
+			opener = j
+			break
+		}
+		if opener < 0 {
+			continue
+		}
+
+		o := nodes[opener]
+		use, name := 1, "em"
+		if c.delim == '~' {
+			use, name = 2, "del"
+		} else if o.count >= 2 && c.count >= 2 {
+			use, name = 2, "strong"
+		}
+		element := CreateElement(name)
+		element.AddChildTokens(markdownTokens(nodes[opener+1 : closer])...)
+		o.count -= use
+		c.count -= use
+
+		replaced := append([]*markdownInline(nil), nodes[:opener+1]...)
+		replaced = append(replaced, &markdownInline{token: element})
+		nodes = append(replaced, nodes[closer:]...)
+		// 残った区切りでもう一度組を探す
+		closer = opener + 1
+	}
+
+	return nodes
+}
+
+// markdownTokens は残った区切りを文字列にし、隣り合う文字列をまとめて Token にする
+func markdownTokens(nodes []*markdownInline) []Token {
+	var tokens []Token
+	buf := bytes.NewBufferString("")
+	for _, node := range nodes {
+		switch {
+		case node.delim != 0:
+			buf.WriteString(strings.Repeat(string(node.delim), node.count))
+		case node.token.Type() == TypeTextToken:
+			buf.WriteString(node.token.TextToken().Text())
+		default:
+			if buf.Len() != 0 {
+				tokens = append(tokens, CreateTextToken(buf.String()))
+				buf.Reset()
+			}
+			tokens = append(tokens, node.token)
+		}
+	}
+	if buf.Len() != 0 {
+		tokens = append(tokens, CreateTextToken(buf.String()))
+	}
+
+	return tokens
+}
+
+// link は text[start] の [ から始まるリンクか画像を読む
+func (state *markdownState) link(text string, start int, image bool) (Token, int, bool) {
+	closing := matchingBracket(text, start)
+	if closing < 0 {
+		return nil, 0, false
+	}
+	label := text[start+1 : closing]
+	pos := closing + 1
+
+	var href, title string
+	found := false
+	if pos < len(text) && text[pos] == '(' {
+		href, title, pos, found = parseLinkDestination(text, pos)
+	}
+	if !found {
+		// [text][ref], [text][], [text]
+		ref := label
+		end := closing + 1
+		if closing+1 < len(text) && text[closing+1] == '[' {
+			if refEnd := strings.IndexByte(text[closing+1:], ']'); refEnd >= 0 {
+				if refLabel := text[closing+2 : closing+1+refEnd]; strings.TrimSpace(refLabel) != "" {
+					ref = refLabel
+				}
+				end = closing + 2 + refEnd
+			}
+		}
+		definition, ok := state.refs[normalizeMarkdownLabel(ref)]
+		if !ok {
+			return nil, 0, false
+		}
+		href, title, pos = definition.href, definition.title, end
+	}
+
+	var element Tag
+	if image {
+		element = CreateElement("img")
+		element.AddAttr("src", href)
+		alt := CreateElement("span")
+		alt.AddChildTokens(state.inline(label)...)
+		element.AddAttr("alt", alt.TextContent())
+	} else {
+		element = CreateElement("a")
+		element.AddAttr("href", href)
+		element.AddChildTokens(state.inline(label)...)
+	}
+	if title != "" {
+		element.AddAttr("title", title)
+	}
+
+	return element, pos, true
+}
+
+// matchingBracket は text[start] の [ に対応する ] の位置を返す
+func matchingBracket(text string, start int) int {
+	depth := 0
+	for pos := start; pos < len(text); pos++ {
+		switch text[pos] {
+		case '\\':
+			pos++
+		case '`':
+			if end, _, ok := scanCodeSpan(text, pos); ok {
+				pos = end - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return pos
+			}
+		}
+	}
+
+	return -1
+}
+
+// parseLinkDestination は text[pos] の ( から (href "title") を読む
+func parseLinkDestination(text string, pos int) (string, string, int, bool) {
+	pos = skipMarkdownSpace(text, pos+1)
+
+	var href string
+	if pos < len(text) && text[pos] == '<' {
+		end := strings.IndexAny(text[pos+1:], ">\n")
+		if end < 0 || text[pos+1+end] != '>' {
+			return "", "", 0, false
+		}
+		href = text[pos+1 : pos+1+end]
+		pos += end + 2
+	} else {
+		start := pos
+		depth := 0
+		for ; pos < len(text); pos++ {
+			c := text[pos]
+			if c == '\\' && pos+1 < len(text) {
+				pos++
+				continue
+			}
+			if c == ' ' || c == '\t' || c == '\n' || (c == ')' && depth == 0) {
+				break
+			}
+			if c == '(' {
+				depth++
+			} else if c == ')' {
+				depth--
+			}
+		}
+		href = text[start:pos]
+	}
+
+	var title string
+	next := skipMarkdownSpace(text, pos)
+	if next < len(text) && next > pos && strings.IndexByte(`"'(`, text[next]) >= 0 {
+		closeChar := text[next]
+		if closeChar == '(' {
+			closeChar = ')'
+		}
+		end := next + 1
+		for ; end < len(text) && text[end] != closeChar; end++ {
+			if text[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(text) {
+			return "", "", 0, false
+		}
+		title = text[next+1 : end]
+		pos = end + 1
+	}
+
+	pos = skipMarkdownSpace(text, pos)
+	if pos >= len(text) || text[pos] != ')' {
+		return "", "", 0, false
+	}
+
+	return unescapeMarkdown(href), unescapeMarkdown(title), pos + 1, true
+}
+
+// scanCodeSpan は text[pos] から始まる ` の連続と同じ長さの ` までをコードとして読む
+func scanCodeSpan(text string, pos int) (int, string, bool) {
+	run := len(text[pos:]) - len(strings.TrimLeft(text[pos:], "`"))
+	fence := text[pos : pos+run]
+	for search := pos + run; search < len(text); {
+		idx := strings.Index(text[search:], fence)
+		if idx < 0 {
+			return 0, "", false
+		}
+		end := search + idx
+		after := len(text[end:]) - len(strings.TrimLeft(text[end:], "`"))
+		if after != run {
+			search = end + after
+			continue
+		}
+
+		code := strings.Replace(text[pos+run:end], "\n", " ", -1)
+		if len(code) >= 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+			code = code[1 : len(code)-1]
+		}
+		return end + run, code, true
+	}
+
+	return 0, "", false
+}
+
+func markdownAutolink(href string, text string) Tag {
+	a := CreateElement("a")
+	a.AddAttr("href", href)
+	a.AddText(text)
+
+	return a
+}
+
+// unescapeMarkdown はバックスラッシュでのエスケープと文字参照を展開する
+func unescapeMarkdown(text string) string {
+	buf := bytes.NewBufferString("")
+	for pos := 0; pos < len(text); pos++ {
+		if text[pos] == '\\' && pos+1 < len(text) && strings.IndexByte(mdEscapable, text[pos+1]) >= 0 {
+			pos++
+		}
+		buf.WriteByte(text[pos])
+	}
+
+	return html.UnescapeString(buf.String())
+}
+
+// normalizeMarkdownLabel はリンクの参照名を大文字小文字と空白の違いを無視して比べられるようにする
+func normalizeMarkdownLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+func skipMarkdownSpace(text string, pos int) int {
+	for pos < len(text) && (text[pos] == ' ' || text[pos] == '\t' || text[pos] == '\n') {
+		pos++
+	}
+
+	return pos
+}
+
+func skipLineIndent(text string, pos int) int {
+	for pos < len(text) && text[pos] == ' ' {
+		pos++
+	}
+
+	return pos
+}
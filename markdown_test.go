@@ -0,0 +1,156 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMarkdownParser_Convert(t *testing.T) {
+	markdown := `# Title #
+
+Setext
+======
+
+Hello *world* and **bold**, _em_ and ~~gone~~.
+Line with break  
+next line, snake_case_word and ` + "`a < b`" + `.
+
+> quote
+continued
+
+- one
+- [two](/two "Two")
+- [x] done
+
+1. first
+
+2. second
+
+` + "```go" + `
+if a < b {}
+` + "```" + `
+
+    indented
+
+| Name | Score |
+|:-----|------:|
+| a \| b | 1 |
+| c | 2 |
+
+![logo *image*][logo] and <https://example.com> &amp; &copy;
+
+[logo]: /logo.png 'Logo'
+
+---
+`
+
+	result, err := NewMarkdownParser().Convert(NewConverter(), strings.NewReader(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<h1>Title</h1><h1>Setext</h1><p>Hello <em>world</em> and <strong>bold</strong>, <em>em</em> and <del>gone</del>.
+Line with break<br>next line, snake_case_word and <code>a &lt; b</code>.</p><blockquote><p>quote
+continued</p></blockquote><ul><li>one</li><li><a href="/two" title="Two">two</a></li><li><input type="checkbox" disabled checked> done</li></ul><ol><li><p>first</p></li><li><p>second</p></li></ol><pre><code class="language-go">if a &lt; b {}
+</code></pre><pre><code>indented
+</code></pre><table><thead><tr><th align="left">Name</th><th align="right">Score</th></tr></thead><tbody><tr><td align="left">a | b</td><td align="right">1</td></tr><tr><td align="left">c</td><td align="right">2</td></tr></tbody></table><p><img src="/logo.png" alt="logo image" title="Logo"> and <a href="https://example.com">https://example.com</a> &amp; ©</p><hr>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestMarkdownParser_emphasis(t *testing.T) {
+	expects := map[string]string{
+		`*a **b** c*`:     `<p><em>a <strong>b</strong> c</em></p>`,
+		`***both***`:      `<p><em><strong>both</strong></em></p>`,
+		`a * b * c`:       `<p>a * b * c</p>`,
+		`\*not\*`:         `<p>*not*</p>`,
+		`**unclosed`:      `<p>**unclosed</p>`,
+		"`code *x*`":      `<p><code>code *x*</code></p>`,
+		`[a](<b c> "t")`:  `<p><a href="b c" title="t">a</a></p>`,
+		`[missing][none]`: `<p>[missing][none]</p>`,
+	}
+	for markdown, expected := range expects {
+		result, err := NewMarkdownParser().Convert(NewConverter(), strings.NewReader(markdown))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Log("expected:\n", expected, "actual:\n", result)
+			t.Fail()
+		}
+	}
+}
+
+func TestMarkdownParser_html(t *testing.T) {
+	markdown := "<div class=\"note\">\n<b>hi</b>\n</div>\n\ntext"
+
+	result, err := NewMarkdownParser().Convert(NewConverter(), strings.NewReader(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<p>&lt;div class=\"note\"&gt;\n&lt;b&gt;hi&lt;/b&gt;\n&lt;/div&gt;</p><p>text</p>"
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+
+	parser := NewMarkdownParser()
+	parser.HTML = NewConverter()
+	result, err = parser.Convert(NewConverter(), strings.NewReader(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = "<div class=\"note\">\n<b>hi</b>\n</div><p>text</p>"
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestMarkdownParser_pipeline(t *testing.T) {
+	conv := NewConverter()
+	conv.(ConverterOptions).Pipeline().Add(&Pass{
+		Name: "heading-anchor",
+		PreOrder: func(tag Tag) error {
+			if tag.Name() == "h2" {
+				tag.AddAttr("id", Slugify(tag.TextContent()))
+			}
+			return nil
+		},
+	})
+	conv.(ConverterOptions).SetSerializer(NewMinifier())
+
+	result, err := NewMarkdownParser().Convert(conv, strings.NewReader("## Getting Started\n\n- a\n- b\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<h2 id=getting-started>Getting Started</h2><ul><li>a<li>b</ul>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestMarkdownParser_linkPolicy(t *testing.T) {
+	// TokenConsumer の代わりに木に対する処理を Pass にする
+	conv := NewConverter()
+	conv.(ConverterOptions).Pipeline().Add(&Pass{
+		Name: "link-policy",
+		Run: func(root Tag) error {
+			ApplyLinkPolicy(root, NewLinkPolicy("example.com"))
+			return nil
+		},
+	})
+
+	markdown := "[x](javascript:alert(1)) [y](https://other.example.org/) [z](https://example.com/)\n\n    <a href=\"javascript:alert(1)\">code</a>\n"
+	result, err := NewMarkdownParser().Convert(conv, strings.NewReader(markdown))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "<p> <a href=\"https://other.example.org/\" rel=\"nofollow ugc noopener\" target=\"_blank\">y</a> <a href=\"https://example.com/\">z</a></p><pre><code>&lt;a href=\"javascript:alert(1)\"&gt;code&lt;/a&gt;\n</code></pre>"
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
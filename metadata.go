@@ -0,0 +1,438 @@
+package html2html
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+)
+
+// MetadataFormatMicrodata と MetadataFormatRDFa は MetadataItem の書き方
+const (
+	MetadataFormatMicrodata = "microdata"
+	MetadataFormatRDFa      = "rdfa"
+)
+
+// Metadata はページの head などに書かれたメタデータ。
+// URL は MetadataExtractor.Base と <base href> で絶対URLにする。
+type Metadata struct {
+	// Title は <title>、無ければ og:title, twitter:title
+	Title string `json:"title,omitempty"`
+	// Description は meta name=description、無ければ og:description, twitter:description
+	Description string `json:"description,omitempty"`
+	Canonical   string `json:"canonical,omitempty"`
+	// OpenGraph は og:, article: などの property ごとの content。og:image のように繰り返すものは順番に並ぶ
+	OpenGraph map[string][]string `json:"openGraph,omitempty"`
+	// Twitter は twitter: で始まる name ごとの content
+	Twitter map[string]string        `json:"twitter,omitempty"`
+	JSONLD  []map[string]interface{} `json:"jsonLD,omitempty"`
+	// JSONLDErrors は読めなかった JSON-LD の script ごとのエラー。その script は JSONLD に含めない
+	JSONLDErrors []string        `json:"jsonLDErrors,omitempty"`
+	Items        []*MetadataItem `json:"items,omitempty"`
+	Favicons     []*MetadataLink `json:"favicons,omitempty"`
+	Feeds        []*MetadataLink `json:"feeds,omitempty"`
+}
+
+// MetadataItem は microdata の itemscope か RDFa の typeof の付いた要素。
+// Properties の値は string か、入れ子になった *MetadataItem
+type MetadataItem struct {
+	Format     string                   `json:"format"`
+	Type       []string                 `json:"type,omitempty"`
+	ID         string                   `json:"id,omitempty"`
+	Properties map[string][]interface{} `json:"properties"`
+}
+
+// MetadataLink は favicon やフィードの <link>
+type MetadataLink struct {
+	Rel   string `json:"rel"`
+	Href  string `json:"href"`
+	Type  string `json:"type,omitempty"`
+	Title string `json:"title,omitempty"`
+	Sizes string `json:"sizes,omitempty"`
+}
+
+// OpenGraphPrefixes は Metadata.OpenGraph に入れる property の接頭辞
+var OpenGraphPrefixes = []string{"og:", "article:", "book:", "profile:", "music:", "video:", "fb:"}
+
+// FeedTypes は Metadata.Feeds に入れる rel=alternate の type
+var FeedTypes = []string{"application/rss+xml", "application/atom+xml", "application/feed+json", "application/json"}
+
+// MetadataExtractor は Tag の木から Metadata を取り出す
+type MetadataExtractor struct {
+	// Base は相対URLを解決する基準。nil なら <base href> だけで解決する
+	Base *url.URL
+}
+
+func NewMetadataExtractor(base *url.URL) *MetadataExtractor {
+	return &MetadataExtractor{Base: base}
+}
+
+// ExtractMetadata は NewMetadataExtractor(nil) で root から Metadata を取り出す
+func ExtractMetadata(root Tag) (*Metadata, error) {
+	return NewMetadataExtractor(nil).Extract(root)
+}
+
+// Extract は root から Metadata を取り出す。壊れた JSON-LD は飛ばして Metadata.JSONLDErrors に記録する
+func (extractor *MetadataExtractor) Extract(root Tag) (*Metadata, error) {
+	base := extractor.Base
+	if bases := root.GetElementsByTagName("base"); len(bases) != 0 {
+		if href := bases[0].GetAttr("href"); href != nil {
+			if u, err := url.Parse(strings.TrimSpace(href.Value)); err == nil {
+				if base != nil {
+					u = base.ResolveReference(u)
+				}
+				base = u
+			}
+		}
+	}
+	resolve := func(rawURL string) string {
+		rawURL = strings.TrimSpace(rawURL)
+		if base == nil || rawURL == "" {
+			return rawURL
+		}
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return rawURL
+		}
+		return base.ResolveReference(u).String()
+	}
+
+	metadata := &Metadata{
+		OpenGraph: make(map[string][]string),
+		Twitter:   make(map[string]string),
+	}
+
+	if titles := root.GetElementsByTagName("title"); len(titles) != 0 {
+		metadata.Title = strings.TrimSpace(collapseSpace(titles[0].TextContent()))
+	}
+
+	for _, meta := range root.GetElementsByTagName("meta") {
+		content := meta.GetAttr("content")
+		if content == nil {
+			continue
+		}
+		value := strings.TrimSpace(content.Value)
+
+		if property := meta.GetAttr("property"); property != nil {
+			key := strings.ToLower(strings.TrimSpace(property.Value))
+			for _, prefix := range OpenGraphPrefixes {
+				if strings.HasPrefix(key, prefix) {
+					if isMetadataURLProperty(key) {
+						value = resolve(value)
+					}
+					metadata.OpenGraph[key] = append(metadata.OpenGraph[key], value)
+					break
+				}
+			}
+		}
+
+		for _, attrKey := range []string{"name", "property"} {
+			name := meta.GetAttr(attrKey)
+			if name == nil {
+				continue
+			}
+			key := strings.ToLower(strings.TrimSpace(name.Value))
+			switch {
+			case key == "description" && attrKey == "name" && metadata.Description == "":
+				metadata.Description = value
+			case strings.HasPrefix(key, "twitter:"):
+				if _, ok := metadata.Twitter[key]; !ok {
+					if key == "twitter:image" || key == "twitter:image:src" {
+						value = resolve(value)
+					}
+					metadata.Twitter[key] = value
+				}
+			}
+		}
+	}
+
+	for _, link := range root.GetElementsByTagName("link") {
+		href := link.GetAttr("href")
+		if href == nil || strings.TrimSpace(href.Value) == "" {
+			continue
+		}
+		rel := strings.ToLower(strings.Join(strings.Fields(attrValue(link, "rel")), " "))
+		linkType := strings.ToLower(strings.TrimSpace(attrValue(link, "type")))
+		rels := strings.Fields(rel)
+
+		switch {
+		case containsString(rels, "canonical"):
+			if metadata.Canonical == "" {
+				metadata.Canonical = resolve(href.Value)
+			}
+		case containsString(rels, "icon") || containsString(rels, "apple-touch-icon") || containsString(rels, "mask-icon"):
+			metadata.Favicons = append(metadata.Favicons, &MetadataLink{
+				Rel:   rel,
+				Href:  resolve(href.Value),
+				Type:  linkType,
+				Sizes: attrValue(link, "sizes"),
+			})
+		case containsString(rels, "alternate") && containsString(FeedTypes, linkType):
+			metadata.Feeds = append(metadata.Feeds, &MetadataLink{
+				Rel:   rel,
+				Href:  resolve(href.Value),
+				Type:  linkType,
+				Title: attrValue(link, "title"),
+			})
+		}
+	}
+
+	for _, script := range root.GetElementsByTagName("script") {
+		if !script.HasAttrValueCaseInsensitive("type", "application/ld+json") {
+			continue
+		}
+		objects, err := parseJSONLD(script.TextContent())
+		if err != nil {
+			metadata.JSONLDErrors = append(metadata.JSONLDErrors, err.Error())
+			continue
+		}
+		metadata.JSONLD = append(metadata.JSONLD, objects...)
+	}
+
+	metadata.Items = extractMetadataItems(root, root, resolve)
+
+	if metadata.Title == "" {
+		metadata.Title = firstNonEmpty(firstValue(metadata.OpenGraph["og:title"]), metadata.Twitter["twitter:title"])
+	}
+	if metadata.Description == "" {
+		metadata.Description = firstNonEmpty(firstValue(metadata.OpenGraph["og:description"]), metadata.Twitter["twitter:description"])
+	}
+
+	return metadata, nil
+}
+
+// parseJSONLD は script の中の JSON-LD を読む。配列は要素ごとのオブジェクトにする
+func parseJSONLD(text string) ([]map[string]interface{}, error) {
+	text = strings.TrimSpace(text)
+	// 古いページは CDATA やコメントで囲んでいることがある
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "<![CDATA["), "]]>")
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "<!--"), "-->")
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		return nil, err
+	}
+
+	var objects []map[string]interface{}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		objects = append(objects, value)
+	case []interface{}:
+		for _, v := range value {
+			if object, ok := v.(map[string]interface{}); ok {
+				objects = append(objects, object)
+			}
+		}
+	}
+
+	return objects, nil
+}
+
+// extractMetadataItems は tag の子孫から、他の item のプロパティでない item を探す
+func extractMetadataItems(root Tag, tag Tag, resolve func(string) string) []*MetadataItem {
+	var items []*MetadataItem
+	for _, token := range tag.Tokens() {
+		if token.Type() != TypeTagToken {
+			continue
+		}
+		child := token.Tag()
+		switch {
+		case child.HasAttr("itemscope") && !child.HasAttr("itemprop"):
+			items = append(items, microdataItem(root, child, resolve, make(map[Tag]bool)))
+		case child.HasAttr("typeof") && !child.HasAttr("property"):
+			items = append(items, rdfaItem(child, vocabOf(child), resolve))
+		}
+		items = append(items, extractMetadataItems(root, child, resolve)...)
+	}
+
+	return items
+}
+
+// microdataItem は tag の item を作る。
+// expanding は展開中の item の要素で、itemref で自身や外側の item を指していても繰り返し展開しない
+func microdataItem(root Tag, tag Tag, resolve func(string) string, expanding map[Tag]bool) *MetadataItem {
+	expanding[tag] = true
+	defer delete(expanding, tag)
+
+	item := &MetadataItem{
+		Format:     MetadataFormatMicrodata,
+		Type:       strings.Fields(attrValue(tag, "itemtype")),
+		ID:         attrValue(tag, "itemid"),
+		Properties: make(map[string][]interface{}),
+	}
+
+	// visited は既にプロパティを探した要素。itemref が子孫や同じ要素を重ねて指していても1度だけ数える
+	visited := make(map[Tag]bool)
+	var visit func(tag Tag)
+	visitProperty := func(child Tag) {
+		if visited[child] || expanding[child] {
+			return
+		}
+		visited[child] = true
+		if props := strings.Fields(attrValue(child, "itemprop")); len(props) != 0 {
+			var value interface{}
+			if child.HasAttr("itemscope") {
+				value = microdataItem(root, child, resolve, expanding)
+			} else {
+				value = metadataPropertyValue(child, resolve, "")
+			}
+			for _, prop := range props {
+				item.Properties[prop] = append(item.Properties[prop], value)
+			}
+		}
+		// 入れ子の item のプロパティは含めない
+		if !child.HasAttr("itemscope") {
+			visit(child)
+		}
+	}
+	visit = func(tag Tag) {
+		for _, token := range tag.Tokens() {
+			if token.Type() == TypeTagToken {
+				visitProperty(token.Tag())
+			}
+		}
+	}
+	visit(tag)
+
+	// itemref で指定した id の要素もプロパティにする
+	for _, id := range strings.Fields(attrValue(tag, "itemref")) {
+		if ref := findElementByID(root, id); ref != nil {
+			visitProperty(ref)
+		}
+	}
+
+	return item
+}
+
+func rdfaItem(tag Tag, vocab string, resolve func(string) string) *MetadataItem {
+	item := &MetadataItem{
+		Format:     MetadataFormatRDFa,
+		ID:         attrValue(tag, "resource"),
+		Properties: make(map[string][]interface{}),
+	}
+	for _, t := range strings.Fields(attrValue(tag, "typeof")) {
+		item.Type = append(item.Type, expandRDFaTerm(vocab, t))
+	}
+
+	var visit func(tag Tag, vocab string)
+	visit = func(tag Tag, vocab string) {
+		for _, token := range tag.Tokens() {
+			if token.Type() != TypeTagToken {
+				continue
+			}
+			child := token.Tag()
+			childVocab := vocab
+			if v := child.GetAttr("vocab"); v != nil {
+				childVocab = v.Value
+			}
+			if props := strings.Fields(attrValue(child, "property")); len(props) != 0 {
+				var value interface{}
+				if child.HasAttr("typeof") {
+					value = rdfaItem(child, childVocab, resolve)
+				} else {
+					value = metadataPropertyValue(child, resolve, "resource")
+				}
+				for _, prop := range props {
+					item.Properties[prop] = append(item.Properties[prop], value)
+				}
+			}
+			if !child.HasAttr("typeof") {
+				visit(child, childVocab)
+			}
+		}
+	}
+	visit(tag, vocab)
+
+	return item
+}
+
+// metadataPropertyValue は要素の種類に応じたプロパティの値を返す
+func metadataPropertyValue(tag Tag, resolve func(string) string, resourceAttr string) string {
+	if content := tag.GetAttr("content"); content != nil {
+		return content.Value
+	}
+	if resourceAttr != "" {
+		if resource := tag.GetAttr(resourceAttr); resource != nil {
+			return resolve(resource.Value)
+		}
+	}
+
+	switch strings.ToLower(tag.Name()) {
+	case "audio", "embed", "iframe", "img", "source", "track", "video":
+		return resolve(attrValue(tag, "src"))
+	case "a", "area", "link":
+		return resolve(attrValue(tag, "href"))
+	case "object":
+		return resolve(attrValue(tag, "data"))
+	case "data", "meter":
+		return attrValue(tag, "value")
+	case "time":
+		if datetime := tag.GetAttr("datetime"); datetime != nil {
+			return datetime.Value
+		}
+	}
+
+	return strings.TrimSpace(collapseSpace(tag.TextContent()))
+}
+
+func vocabOf(tag Tag) string {
+	for target := tag; target != nil; target = target.Parent() {
+		if vocab := target.GetAttr("vocab"); vocab != nil {
+			return vocab.Value
+		}
+	}
+
+	return ""
+}
+
+func expandRDFaTerm(vocab, term string) string {
+	if vocab == "" || strings.Contains(term, ":") {
+		return term
+	}
+
+	return vocab + term
+}
+
+func findElementByID(tag Tag, id string) Tag {
+	for _, token := range tag.Tokens() {
+		if token.Type() != TypeTagToken {
+			continue
+		}
+		child := token.Tag()
+		if child.HasAttrValue("id", id) {
+			return child
+		}
+		if found := findElementByID(child, id); found != nil {
+			return found
+		}
+	}
+
+	return nil
+}
+
+func isMetadataURLProperty(key string) bool {
+	switch key {
+	case "og:url", "og:image", "og:image:url", "og:image:secure_url", "og:video", "og:video:url", "og:video:secure_url", "og:audio", "og:audio:url", "og:audio:secure_url":
+		return true
+	}
+
+	return false
+}
+
+func attrValue(tag Tag, attrKey string) string {
+	if attr := tag.GetAttr(attrKey); attr != nil {
+		return attr.Value
+	}
+
+	return ""
+}
+
+func firstValue(values []string) string {
+	if len(values) == 0 {
+		return ""
+	}
+
+	return values[0]
+}
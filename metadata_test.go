@@ -0,0 +1,235 @@
+package html2html
+
+import (
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMetadataExtractor(t *testing.T) {
+	html := `<!DOCTYPE html>
+<html><head>
+<title>  Example
+ Page </title>
+<meta name="description" content="A page about examples.">
+<meta property="og:title" content="Example OG">
+<meta property="og:image" content="/a.png">
+<meta property="og:image" content="https://cdn.example.com/b.png">
+<meta property="article:author" content="Jane">
+<meta name="twitter:card" content="summary_large_image">
+<meta name="twitter:image" content="/tw.png">
+<link rel="canonical" href="/page">
+<link rel="shortcut icon" href="/favicon.ico">
+<link rel="icon" type="image/png" sizes="32x32" href="/icon-32.png">
+<link rel="alternate" type="application/rss+xml" title="RSS" href="/feed.xml">
+<link rel="alternate" hreflang="ja" href="/ja/">
+<script type="application/ld+json">{"@context": "https://schema.org", "@type": "Article", "headline": "Example &amp; more"}</script>
+<script type="application/ld+json">[{"@type": "Person", "name": "Jane"}, 1]</script>
+</head><body></body></html>`
+
+	root, err := NewConverter().Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, _ := url.Parse("https://example.com/dir/")
+	metadata, err := NewMetadataExtractor(base).Extract(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if metadata.Title != "Example Page" {
+		t.Error("unexpected", metadata.Title)
+	}
+	if metadata.Description != "A page about examples." {
+		t.Error("unexpected", metadata.Description)
+	}
+	if metadata.Canonical != "https://example.com/page" {
+		t.Error("unexpected", metadata.Canonical)
+	}
+	expectedOG := map[string][]string{
+		"og:title":       {"Example OG"},
+		"og:image":       {"https://example.com/a.png", "https://cdn.example.com/b.png"},
+		"article:author": {"Jane"},
+	}
+	if !reflect.DeepEqual(metadata.OpenGraph, expectedOG) {
+		t.Error("unexpected", metadata.OpenGraph)
+	}
+	expectedTwitter := map[string]string{
+		"twitter:card":  "summary_large_image",
+		"twitter:image": "https://example.com/tw.png",
+	}
+	if !reflect.DeepEqual(metadata.Twitter, expectedTwitter) {
+		t.Error("unexpected", metadata.Twitter)
+	}
+
+	if len(metadata.JSONLD) != 2 {
+		t.Fatal("unexpected", metadata.JSONLD)
+	}
+	if metadata.JSONLD[0]["headline"] != "Example &amp; more" || metadata.JSONLD[1]["name"] != "Jane" {
+		t.Error("unexpected", metadata.JSONLD)
+	}
+
+	if len(metadata.Favicons) != 2 || metadata.Favicons[0].Href != "https://example.com/favicon.ico" ||
+		metadata.Favicons[1].Sizes != "32x32" || metadata.Favicons[1].Type != "image/png" {
+		t.Error("unexpected", metadata.Favicons)
+	}
+	if len(metadata.Feeds) != 1 || metadata.Feeds[0].Href != "https://example.com/feed.xml" || metadata.Feeds[0].Title != "RSS" {
+		t.Error("unexpected", metadata.Feeds)
+	}
+}
+
+func TestMetadataExtractor_fallback(t *testing.T) {
+	html := `<head><base href="https://example.org/base/"><meta property="og:title" content="OG Title">
+<meta name="twitter:description" content="Twitter description">
+<meta property="og:url" content="page.html"></head>`
+
+	metadata, err := ExtractMetadata(mustParse(t, html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "OG Title" {
+		t.Error("unexpected", metadata.Title)
+	}
+	if metadata.Description != "Twitter description" {
+		t.Error("unexpected", metadata.Description)
+	}
+	if url := metadata.OpenGraph["og:url"]; len(url) != 1 || url[0] != "https://example.org/base/page.html" {
+		t.Error("unexpected", url)
+	}
+}
+
+func TestMetadataExtractor_brokenJSONLD(t *testing.T) {
+	html := `<title>Broken</title>
+<script type="application/ld+json">{"@type": </script>
+<script type="application/ld+json">{"@type": "Article"}</script>`
+
+	metadata, err := ExtractMetadata(mustParse(t, html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if metadata.Title != "Broken" {
+		t.Error("unexpected", metadata.Title)
+	}
+	if len(metadata.JSONLD) != 1 || metadata.JSONLD[0]["@type"] != "Article" {
+		t.Error("unexpected", metadata.JSONLD)
+	}
+	if len(metadata.JSONLDErrors) != 1 {
+		t.Error("unexpected", metadata.JSONLDErrors)
+	}
+}
+
+func TestMetadataExtractor_microdata(t *testing.T) {
+	html := `<div itemscope itemtype="https://schema.org/Product" itemref="extra">
+<span itemprop="name">Widget</span>
+<img itemprop="image" src="/widget.png">
+<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+<meta itemprop="price" content="9.99"><span itemprop="priceCurrency">USD</span>
+</div>
+<time itemprop="releaseDate" datetime="2016-01-01">New Year</time>
+</div>
+<p id="extra" itemprop="description">A small widget.</p>`
+
+	metadata, err := NewMetadataExtractor(&url.URL{Scheme: "https", Host: "shop.example.com"}).Extract(mustParse(t, html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata.Items) != 1 {
+		t.Fatal("unexpected", metadata.Items)
+	}
+
+	item := metadata.Items[0]
+	if item.Format != MetadataFormatMicrodata || !reflect.DeepEqual(item.Type, []string{"https://schema.org/Product"}) {
+		t.Error("unexpected", item)
+	}
+	expected := map[string][]interface{}{
+		"name":        {"Widget"},
+		"image":       {"https://shop.example.com/widget.png"},
+		"releaseDate": {"2016-01-01"},
+		"description": {"A small widget."},
+		"offers": {&MetadataItem{
+			Format:     MetadataFormatMicrodata,
+			Type:       []string{"https://schema.org/Offer"},
+			Properties: map[string][]interface{}{"price": {"9.99"}, "priceCurrency": {"USD"}},
+		}},
+	}
+	if !reflect.DeepEqual(item.Properties, expected) {
+		t.Error("unexpected", item.Properties)
+	}
+}
+
+func TestMetadataExtractor_rdfa(t *testing.T) {
+	html := `<div vocab="https://schema.org/" typeof="Person">
+<span property="name">Jane Doe</span>
+<a property="url" href="https://jane.example.com/">home</a>
+<div property="address" typeof="PostalAddress"><span property="addressLocality">Tokyo</span></div>
+</div>`
+
+	metadata, err := ExtractMetadata(mustParse(t, html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata.Items) != 1 {
+		t.Fatal("unexpected", metadata.Items)
+	}
+
+	item := metadata.Items[0]
+	if item.Format != MetadataFormatRDFa || !reflect.DeepEqual(item.Type, []string{"https://schema.org/Person"}) {
+		t.Error("unexpected", item)
+	}
+	expected := map[string][]interface{}{
+		"name": {"Jane Doe"},
+		"url":  {"https://jane.example.com/"},
+		"address": {&MetadataItem{
+			Format:     MetadataFormatRDFa,
+			Type:       []string{"https://schema.org/PostalAddress"},
+			Properties: map[string][]interface{}{"addressLocality": {"Tokyo"}},
+		}},
+	}
+	if !reflect.DeepEqual(item.Properties, expected) {
+		t.Error("unexpected", item.Properties)
+	}
+}
+
+func mustParse(t *testing.T, html string) Tag {
+	root, err := NewConverter().Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return root
+}
+
+func TestMetadataExtractor_itemrefCycle(t *testing.T) {
+	html := `<div itemscope><div id="s" itemprop="a" itemscope itemref="s">x<span itemprop="b">y</span></div></div>
+<div itemscope itemref="q"><span id="p" itemprop="c" itemscope itemref="q">1</span><span id="q" itemprop="d" itemscope itemref="p">2</span></div>`
+
+	metadata, err := ExtractMetadata(mustParse(t, html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata.Items) != 2 {
+		t.Fatal("unexpected", metadata.Items)
+	}
+
+	expected := map[string][]interface{}{
+		"a": {&MetadataItem{
+			Format:     MetadataFormatMicrodata,
+			Type:       []string{},
+			Properties: map[string][]interface{}{"b": {"y"}},
+		}},
+	}
+	if !reflect.DeepEqual(metadata.Items[0].Properties, expected) {
+		t.Error("unexpected", metadata.Items[0].Properties)
+	}
+
+	// p と q は互いを itemref で指すので、展開中の item は中に含めない
+	empty := &MetadataItem{Format: MetadataFormatMicrodata, Type: []string{}, Properties: map[string][]interface{}{}}
+	expected = map[string][]interface{}{
+		"c": {&MetadataItem{Format: MetadataFormatMicrodata, Type: []string{}, Properties: map[string][]interface{}{"d": {empty}}}},
+		"d": {&MetadataItem{Format: MetadataFormatMicrodata, Type: []string{}, Properties: map[string][]interface{}{"c": {empty}}}},
+	}
+	if !reflect.DeepEqual(metadata.Items[1].Properties, expected) {
+		t.Error("unexpected", metadata.Items[1].Properties)
+	}
+}
@@ -0,0 +1,307 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+)
+
+var _ Serializer = &Minifier{}
+
+// optionalEndTags は閉じタグを省略できる要素と、その後に続いてもよい要素。
+// nil の要素が含まれていれば親の最後の子の場合にも省略できる。
+// see https://html.spec.whatwg.org/multipage/syntax.html#optional-tags
+var optionalEndTags = map[string][]string{
+	"li":       {"li", ""},
+	"dt":       {"dt", "dd"},
+	"dd":       {"dd", "dt", ""},
+	"option":   {"option", "optgroup", ""},
+	"optgroup": {"optgroup", ""},
+	"tr":       {"tr", ""},
+	"td":       {"td", "th", ""},
+	"th":       {"td", "th", ""},
+	"thead":    {"tbody", "tfoot"},
+	"tbody":    {"tbody", "tfoot", ""},
+	"tfoot":    {""},
+	"p": {"address", "article", "aside", "blockquote", "details", "div", "dl", "fieldset", "figcaption", "figure", "footer", "form",
+		"h1", "h2", "h3", "h4", "h5", "h6", "header", "hgroup", "hr", "main", "menu", "nav", "ol", "p", "pre", "section", "table", "ul", ""},
+}
+
+// pEndTagRequiredParents の中の最後の <p> は閉じタグを省略できない
+var pEndTagRequiredParents = []string{"a", "audio", "del", "ins", "map", "noscript", "video"}
+
+// minifyTransparentElements は表示されない要素。前後の空白はその外側の要素で決まる
+var minifyTransparentElements = []string{"script", "style", "noscript", "template", "meta", "link", "base"}
+
+// whitespaceInsignificantElements の直下の空白だけの文字列は表示されない
+var whitespaceInsignificantElements = []string{"html", "head", "table", "thead", "tbody", "tfoot", "tr", "colgroup", "ul", "ol", "dl", "select", "optgroup"}
+
+// Minifier は意味を変えずにできるだけ短く出力する Serializer。
+// 空白をまとめ、条件付きコメント以外のコメント、省略できる閉じタグ、不要な属性の引用符を取り除く。
+type Minifier struct {
+	KeepComments        bool
+	KeepOptionalEndTags bool
+	KeepAttrQuotes      bool
+	// MinifyCSS が設定されていれば <style> の中身と style 属性に使う
+	MinifyCSS func(css string) (string, error)
+	// MinifyJS が設定されていれば JavaScript の <script> の中身に使う
+	MinifyJS func(js string) (string, error)
+}
+
+func NewMinifier() *Minifier {
+	return &Minifier{}
+}
+
+func (minifier *Minifier) Serialize(buf *bytes.Buffer, token Token) error {
+	if token.Type() == TypeTagToken && token.Tag().IsDocumentRoot() {
+		return minifier.children(buf, token.Tag())
+	}
+
+	return minifier.write(buf, token, nil)
+}
+
+// minifyNode は出力するTokenと、空白をまとめた後の文字列
+type minifyNode struct {
+	token Token
+	text  string
+}
+
+func (minifier *Minifier) children(buf *bytes.Buffer, tag Tag) error {
+	nodes := collapseChildren(tag, minifier.KeepComments)
+	for idx, node := range nodes {
+		if node.token.Type() == TypeTextToken {
+			buf.WriteString(node.text)
+			continue
+		}
+
+		var next Token
+		if idx+1 < len(nodes) {
+			next = nodes[idx+1].token
+		}
+		if err := minifier.write(buf, node.token, next); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collapseChildren は tag の子のうち出力するものを、文字列の空白をまとめて返す。
+// keepComments が false なら条件付きコメント以外のコメントを取り除く。
+func collapseChildren(tag Tag, keepComments bool) []minifyNode {
+	var nodes []minifyNode
+	for _, token := range tag.Tokens() {
+		switch token.Type() {
+		case TypeCommentToken:
+			if keepComments || isConditionalComment(token.TextToken().Text()) {
+				nodes = append(nodes, minifyNode{token: token})
+			}
+		case TypeTextToken:
+			text := collapseSpace(token.TextToken().Text())
+			if last := len(nodes) - 1; last >= 0 && nodes[last].token.Type() == TypeTextToken {
+				// 取り除いたコメントの前後の文字列をつなげる
+				nodes[last].text = collapseSpace(nodes[last].text + text)
+				continue
+			}
+			nodes = append(nodes, minifyNode{token: token, text: text})
+		default:
+			nodes = append(nodes, minifyNode{token: token})
+		}
+	}
+
+	// ブロックの始まりと終わり、ブロック要素の前後の空白は表示に影響しない
+	blockParent := tag.IsDocumentRoot() || IsBlockElement(tag)
+	var result []minifyNode
+	for idx, node := range nodes {
+		if node.token.Type() != TypeTextToken {
+			result = append(result, node)
+			continue
+		}
+
+		if isBlockBoundary(nodes, idx, -1, blockParent) {
+			node.text = strings.TrimLeftFunc(node.text, unicode.IsSpace)
+		}
+		if isBlockBoundary(nodes, idx, 1, blockParent) {
+			node.text = strings.TrimRightFunc(node.text, unicode.IsSpace)
+		}
+		if containsString(whitespaceInsignificantElements, tag.Name()) {
+			node.text = strings.TrimSpace(node.text)
+		}
+		if node.text != "" {
+			result = append(result, node)
+		}
+	}
+
+	return result
+}
+
+func (minifier *Minifier) write(buf *bytes.Buffer, token Token, next Token) error {
+	if token.Type() != TypeTagToken {
+		token.BuildHTML(buf)
+		return nil
+	}
+
+	tag := token.Tag()
+	if err := minifier.startTag(buf, tag); err != nil {
+		return err
+	}
+	if tag.IsSelfClosing() || (IsVoidElement(tag) && len(tag.Tokens()) == 0) {
+		return nil
+	}
+
+	switch tag.Name() {
+	case "pre", "textarea":
+		for _, child := range tag.Tokens() {
+			child.BuildHTML(buf)
+		}
+	case "style", "script":
+		text := tag.TextContent()
+		if tag.Name() == "style" && minifier.MinifyCSS != nil {
+			var err error
+			if text, err = minifier.MinifyCSS(text); err != nil {
+				return err
+			}
+		} else if tag.Name() == "script" && minifier.MinifyJS != nil && isJavaScriptTag(tag) {
+			var err error
+			if text, err = minifier.MinifyJS(text); err != nil {
+				return err
+			}
+		}
+		buf.WriteString(text)
+	default:
+		if err := minifier.children(buf, tag); err != nil {
+			return err
+		}
+	}
+
+	if minifier.KeepOptionalEndTags || !canOmitEndTag(tag, next) {
+		buildEndTag(buf, tag)
+	}
+
+	return nil
+}
+
+func (minifier *Minifier) startTag(buf *bytes.Buffer, tag Tag) error {
+	buf.WriteString("<")
+	buf.WriteString(tag.Name())
+	for _, attr := range tag.Attrs() {
+		buf.WriteString(" ")
+		buf.WriteString(attr.Key)
+
+		value := attr.Value
+		if containsString(BooleanAttributes, attr.Key) || value == "" {
+			continue
+		}
+		if attr.Key == "style" && minifier.MinifyCSS != nil {
+			var err error
+			if value, err = minifier.MinifyCSS(value); err != nil {
+				return err
+			}
+		}
+
+		buf.WriteString("=")
+		if !minifier.KeepAttrQuotes && canUnquoteAttrValue(value) {
+			buf.WriteString(value)
+		} else {
+			buf.WriteString("\"")
+			buf.WriteString(value)
+			buf.WriteString("\"")
+		}
+	}
+	if tag.IsSelfClosing() {
+		buf.WriteString("/>")
+	} else {
+		buf.WriteString(">")
+	}
+
+	return nil
+}
+
+func canOmitEndTag(tag Tag, next Token) bool {
+	followers, ok := optionalEndTags[tag.Name()]
+	if !ok {
+		return false
+	}
+
+	if next == nil {
+		if tag.Name() == "p" && tag.Parent() != nil && containsString(pEndTagRequiredParents, tag.Parent().Name()) {
+			return false
+		}
+		// document root の直下では後ろに何が続くかわからない
+		return tag.Parent() != nil && !tag.Parent().IsDocumentRoot() && containsString(followers, "")
+	}
+	if next.Type() != TypeTagToken {
+		return false
+	}
+
+	return containsString(followers, next.Tag().Name())
+}
+
+// canUnquoteAttrValue は属性値を引用符無しで書けるかを返す
+// see https://html.spec.whatwg.org/multipage/syntax.html#unquoted
+func canUnquoteAttrValue(value string) bool {
+	if value == "" || strings.HasSuffix(value, "/") {
+		return false
+	}
+
+	return !strings.ContainsAny(value, " \t\n\f\r\"'=<>`")
+}
+
+func isConditionalComment(text string) bool {
+	return strings.HasPrefix(text, "[if ") || strings.HasPrefix(text, "<![endif]")
+}
+
+func isJavaScriptTag(tag Tag) bool {
+	attr := tag.GetAttr("type")
+	if attr == nil {
+		return true
+	}
+
+	switch strings.ToLower(strings.TrimSpace(attr.Value)) {
+	case "", "module", "text/javascript", "application/javascript":
+		return true
+	}
+
+	return false
+}
+
+// isBlockBoundary は nodes[idx] の step の向きの隣がブロック要素かブロックの端かを返す。
+// minifyTransparentElements は表示されないので、その先を見る
+func isBlockBoundary(nodes []minifyNode, idx int, step int, blockParent bool) bool {
+	for idx += step; idx >= 0 && idx < len(nodes); idx += step {
+		token := nodes[idx].token
+		if token.Type() == TypeTagToken && containsString(minifyTransparentElements, token.Tag().Name()) {
+			continue
+		}
+		return isMinifyBlock(token)
+	}
+
+	return blockParent
+}
+
+func isMinifyBlock(token Token) bool {
+	if token.Type() == TypeDoctypeToken {
+		return true
+	}
+
+	return token.Type() == TypeTagToken && IsBlockElement(token.Tag())
+}
+
+// collapseSpace は連続する空白を1つの空白にする
+func collapseSpace(text string) string {
+	buf := bytes.NewBufferString("")
+	space := false
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			if !space {
+				buf.WriteByte(' ')
+			}
+			space = true
+			continue
+		}
+		buf.WriteRune(r)
+		space = false
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,94 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMinifier(t *testing.T) {
+	html := `<!DOCTYPE html>
+<html>
+  <head>
+    <title>Hello</title>
+    <!-- remove me -->
+    <!--[if IE]><p>IE</p><![endif]-->
+  </head>
+  <body>
+    <div class="a b"   id="main">
+      <p>Hello,   <b>big</b>
+      world!</p>
+      <p>second</p>
+      <pre>  keep
+   this  </pre>
+      <ul>
+        <li>one</li>
+        <li><a href="/path/">two</a></li>
+      </ul>
+      <input type="checkbox" checked="checked" disabled="">
+      <select><option value="1" selected>1</option><option value="2">2</option></select>
+      <a href="#"><p>in a</p></a>
+    </div>
+  </body>
+</html>
+`
+
+	conv := NewConverter()
+	conv.(ConverterOptions).SetSerializer(NewMinifier())
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<!DOCTYPE html><html><head><title>Hello</title><!--[if IE]><p>IE</p><![endif]--></head><body><div class="a b" id=main><p>Hello, <b>big</b> world!<p>second<pre>  keep
+   this  </pre><ul><li>one<li><a href="/path/">two</a></ul><input type=checkbox checked disabled> <select><option value=1 selected>1<option value=2>2</select> <a href=#><p>in a</p></a></div></body></html>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestMinifier_hooks(t *testing.T) {
+	html := `<style> p { color: red; } </style><script> var a = 1; </script><script type="application/ld+json"> {} </script><p style="color: red;">x</p>`
+
+	conv := NewConverter()
+	minifier := NewMinifier()
+	minifier.MinifyCSS = func(css string) (string, error) {
+		return strings.Join(strings.Fields(css), ""), nil
+	}
+	minifier.MinifyJS = func(js string) (string, error) {
+		return strings.TrimSpace(js), nil
+	}
+	conv.(ConverterOptions).SetSerializer(minifier)
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<style>p{color:red;}</style><script>var a = 1;</script><script type=application/ld+json> {} </script><p style=color:red;>x</p>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestMinifier_transparentElements(t *testing.T) {
+	conv := NewConverter()
+	conv.(ConverterOptions).SetSerializer(NewMinifier())
+
+	for html, expected := range map[string]string{
+		`<p>a <script>x()</script> b</p>`:                 `<p>a <script>x()</script> b</p>`,
+		`<p>a <noscript>js</noscript> b</p>`:              `<p>a <noscript>js</noscript> b</p>`,
+		`<div> <script>x()</script> <p>a</p> </div>`:      `<div><script>x()</script><p>a</div>`,
+		`<p><input hidden="until-found"> <b>b</b></p>`:    `<p><input hidden=until-found> <b>b</b></p>`,
+		"<body>\n<p>a</p>\n<script>x()</script>\n</body>": `<body><p>a</p><script>x()</script></body>`,
+	} {
+		result, err := conv.Convert(strings.NewReader(html))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result != expected {
+			t.Log("expected:\n", expected, "actual:\n", result)
+			t.Fail()
+		}
+	}
+}
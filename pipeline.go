@@ -0,0 +1,157 @@
+package html2html
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Pass は Parse した後の Tag の木に対する処理。
+// Run は木全体に対して1度、PreOrder と PostOrder は各要素(document root を含む)に対して子より前と後に呼ばれる。
+// PreOrder の中で tag の子を置き換えると、置き換えた後の子が辿られる。
+type Pass struct {
+	Name      string
+	Run       func(root Tag) error
+	PreOrder  func(tag Tag) error
+	PostOrder func(tag Tag) error
+}
+
+// PassTiming は Pass 1つの実行時間
+type PassTiming struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Pipeline は Pass を登録順に実行する。Converter.Convert は Parse と BuildHTML の間でこれを実行する
+type Pipeline struct {
+	mu       sync.Mutex
+	passes   []*Pass
+	disabled map[string]bool
+	timings  []PassTiming
+}
+
+func NewPipeline(passes ...*Pass) *Pipeline {
+	return &Pipeline{
+		passes:   passes,
+		disabled: make(map[string]bool),
+	}
+}
+
+// Add は pass を末尾に追加する
+func (pipeline *Pipeline) Add(pass *Pass) {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	pipeline.passes = append(pipeline.passes, pass)
+}
+
+// Names は登録されている Pass の名前を実行順に返す
+func (pipeline *Pipeline) Names() []string {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	names := make([]string, 0, len(pipeline.passes))
+	for _, pass := range pipeline.passes {
+		names = append(names, pass.Name)
+	}
+
+	return names
+}
+
+func (pipeline *Pipeline) Enable(name string) {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	delete(pipeline.disabled, name)
+}
+
+func (pipeline *Pipeline) Disable(name string) {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	if pipeline.disabled == nil {
+		pipeline.disabled = make(map[string]bool)
+	}
+	pipeline.disabled[name] = true
+}
+
+func (pipeline *Pipeline) Enabled(name string) bool {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	return !pipeline.disabled[name]
+}
+
+// Timings は最後に Run した時の Pass ごとの実行時間を返す
+func (pipeline *Pipeline) Timings() []PassTiming {
+	pipeline.mu.Lock()
+	defer pipeline.mu.Unlock()
+
+	return pipeline.timings
+}
+
+// Run は有効な Pass を順に root に対して実行し、Pass ごとの実行時間を返す
+func (pipeline *Pipeline) Run(root Tag) ([]PassTiming, error) {
+	pipeline.mu.Lock()
+	var passes []*Pass
+	for _, pass := range pipeline.passes {
+		if !pipeline.disabled[pass.Name] {
+			passes = append(passes, pass)
+		}
+	}
+	pipeline.mu.Unlock()
+
+	timings := make([]PassTiming, 0, len(passes))
+	for _, pass := range passes {
+		start := time.Now()
+		err := pass.run(root)
+		timings = append(timings, PassTiming{Name: pass.Name, Duration: time.Since(start)})
+		if err != nil {
+			return timings, fmt.Errorf("pass %s: %w", pass.Name, err)
+		}
+	}
+
+	pipeline.mu.Lock()
+	pipeline.timings = timings
+	pipeline.mu.Unlock()
+
+	return timings, nil
+}
+
+func (pass *Pass) run(root Tag) error {
+	if pass.Run != nil {
+		if err := pass.Run(root); err != nil {
+			return err
+		}
+	}
+	if pass.PreOrder != nil || pass.PostOrder != nil {
+		return pass.visit(root)
+	}
+
+	return nil
+}
+
+func (pass *Pass) visit(tag Tag) error {
+	if pass.PreOrder != nil {
+		if err := pass.PreOrder(tag); err != nil {
+			return err
+		}
+	}
+
+	// 子の処理中に tag の子が変わっても影響を受けないように、ここでの子を辿る
+	children := append([]Token(nil), tag.Tokens()...)
+	for _, token := range children {
+		if token.Type() != TypeTagToken {
+			continue
+		}
+		if err := pass.visit(token.Tag()); err != nil {
+			return err
+		}
+	}
+
+	if pass.PostOrder != nil {
+		return pass.PostOrder(tag)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package html2html
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPipeline(t *testing.T) {
+	html := "<b><strike>Foobar<strike>FizzBuzz"
+
+	conv := NewConverter()
+	conv.SetRaiseErrorOnInvalidEndTag(false)
+	conv.(ConverterOptions).Pipeline().Add(&Pass{
+		Name: "strike",
+		PreOrder: func(tag Tag) error {
+			for _, token := range tag.Tokens() {
+				if token.Type() != TypeTagToken || token.Tag().Name() != "strike" {
+					continue
+				}
+				altTag := CreateElement("span")
+				altTag.AddAttr("class", "strike")
+				altTag.SetTokens(token.Tag().Tokens())
+				tag.ReplateChildToken(token, altTag)
+			}
+			return nil
+		},
+	})
+	var depths []int
+	conv.(ConverterOptions).Pipeline().Add(&Pass{
+		Name: "depth",
+		PostOrder: func(tag Tag) error {
+			depth := 0
+			for parent := tag.Parent(); parent != nil; parent = parent.Parent() {
+				depth++
+			}
+			depths = append(depths, depth)
+			return nil
+		},
+	})
+	conv.(ConverterOptions).Pipeline().Add(&Pass{
+		Name: "fail",
+		Run: func(root Tag) error {
+			return errors.New("failed")
+		},
+	})
+	conv.(ConverterOptions).Pipeline().Disable("fail")
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != `<b><span class="strike">Foobar<span class="strike">FizzBuzz</span></span></b>` {
+		t.Error("unexpected", result)
+	}
+	if len(depths) != 4 || depths[0] != 3 || depths[3] != 0 {
+		t.Error("unexpected", depths)
+	}
+	if timings := conv.(ConverterOptions).Pipeline().Timings(); len(timings) != 2 || timings[0].Name != "strike" || timings[1].Name != "depth" {
+		t.Error("unexpected", timings)
+	}
+
+	conv.(ConverterOptions).Pipeline().Enable("fail")
+	_, err = conv.Convert(strings.NewReader(html))
+	if err == nil || err.Error() != "pass fail: failed" {
+		t.Error("unexpected", err)
+	}
+}
+
+func TestPipeline_zeroValue(t *testing.T) {
+	pipeline := &Pipeline{}
+	pipeline.Add(&Pass{Name: "a"})
+	pipeline.Disable("a")
+	if pipeline.Enabled("a") {
+		t.Error("unexpected")
+	}
+	pipeline.Enable("a")
+	if !pipeline.Enabled("a") {
+		t.Error("unexpected")
+	}
+	if _, err := pipeline.Run(CreateDocumentRoot()); err != nil {
+		t.Error("unexpected", err)
+	}
+}
@@ -0,0 +1,232 @@
+package html2html
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// DefaultPresets は組み込みの preset を登録済みの PresetRegistry
+var DefaultPresets = NewPresetRegistry()
+
+func init() {
+	for name, cfg := range builtinPresets {
+		DefaultPresets.Register(name, cfg)
+	}
+}
+
+// unsafeElements はユーザ投稿に含まれていてはいけない要素
+var unsafeElements = []string{"script", "style", "iframe", "frame", "frameset", "object", "embed", "applet", "base", "link", "meta", "form", "input", "button", "select", "textarea"}
+
+var builtinPresets = map[string]*Config{
+	"default": {},
+	// safe はスクリプトと埋め込みを取り除き、外部リンクに rel と target を付ける
+	"safe": {
+		Drop:  []string{"script", "style", "iframe", "object", "embed"},
+		Links: &LinkConfig{},
+	},
+	// comment はユーザのコメント向け。書式以外の要素と属性を取り除き、外部リンクに rel と target を付ける
+	"comment": {
+		Lenient: boolPtr(true),
+		Drop:    unsafeElements,
+		Links:   &LinkConfig{},
+		Unwrap:  []string{"font", "center"},
+		Attributes: map[string][]string{
+			"*":   {"title"},
+			"a":   {"href"},
+			"img": {"src", "alt", "width", "height"},
+		},
+	},
+	// article は記事本文向け。comment に加えて埋め込みと class, id を許す
+	"article": {
+		Extends: "comment",
+		Keep:    []string{"iframe"},
+		Attributes: map[string][]string{
+			"*":      {"title", "class", "id", "lang", "dir"},
+			"iframe": {"src", "width", "height", "allowfullscreen"},
+			"img":    {"src", "srcset", "sizes", "alt", "width", "height"},
+			"td":     {"colspan", "rowspan"},
+			"th":     {"colspan", "rowspan", "scope"},
+		},
+	},
+	// amp は AMP HTML 向け。埋め込み要素を amp-* に置き換える
+	"amp": {
+		Extends: "article",
+		Keep:    []string{"style"},
+		Rename: map[string]string{
+			"img":    "amp-img",
+			"iframe": "amp-iframe",
+			"video":  "amp-video",
+			"audio":  "amp-audio",
+		},
+	},
+	// email はHTMLメール向け。スクリプトや埋め込みを取り除く
+	"email": {
+		Lenient: boolPtr(true),
+		Drop:    []string{"script", "iframe", "frame", "frameset", "object", "embed", "applet", "form", "input", "button", "select", "textarea", "video", "audio"},
+	},
+}
+
+// PresetRegistry は名前を付けた Config を管理する。複数のgoroutineから使える
+type PresetRegistry struct {
+	mu      sync.RWMutex
+	presets map[string]*Config
+}
+
+func NewPresetRegistry() *PresetRegistry {
+	return &PresetRegistry{presets: make(map[string]*Config)}
+}
+
+// Register は name で cfg を登録する。同じ名前があれば上書きする
+func (registry *PresetRegistry) Register(name string, cfg *Config) {
+	registry.mu.Lock()
+	defer registry.mu.Unlock()
+
+	registry.presets[name] = cfg
+}
+
+// Names は登録されている preset の名前を返す
+func (registry *PresetRegistry) Names() []string {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	names := make([]string, 0, len(registry.presets))
+	for name := range registry.presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Lookup は name の preset を、Extends を解決した状態で返す
+func (registry *PresetRegistry) Lookup(name string) (*Config, error) {
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	return registry.resolve(name, nil)
+}
+
+// Resolve は cfg の Extends をこの PresetRegistry から解決する
+func (registry *PresetRegistry) Resolve(cfg *Config) (*Config, error) {
+	if cfg.Extends == "" {
+		return cfg, nil
+	}
+
+	registry.mu.RLock()
+	defer registry.mu.RUnlock()
+
+	parent, err := registry.resolve(cfg.Extends, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeConfig(parent, cfg), nil
+}
+
+// NewConverter は name の preset から Converter を作る
+func (registry *PresetRegistry) NewConverter(name string) (Converter, error) {
+	cfg, err := registry.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return cfg.NewConverter()
+}
+
+func (registry *PresetRegistry) resolve(name string, visited []string) (*Config, error) {
+	for _, v := range visited {
+		if v == name {
+			return nil, fmt.Errorf("preset %q extends itself", name)
+		}
+	}
+
+	cfg, ok := registry.presets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown preset %q", name)
+	}
+	if cfg.Extends == "" {
+		return cfg, nil
+	}
+
+	parent, err := registry.resolve(cfg.Extends, append(visited, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeConfig(parent, cfg), nil
+}
+
+// mergeConfig は parent を child で上書きした Config を返す。
+// 要素ごとの規則(drop, unwrap, rename)と要素ごとの attributes は child が優先され、lenient, urls, links は child で指定されていれば置き換える。
+func mergeConfig(parent *Config, child *Config) *Config {
+	merged := &Config{
+		Lenient: parent.Lenient,
+		URLs:    parent.URLs,
+		Links:   parent.Links,
+	}
+	if child.Lenient != nil {
+		merged.Lenient = child.Lenient
+	}
+	if child.URLs != nil {
+		merged.URLs = child.URLs
+	}
+	if child.Links != nil {
+		merged.Links = child.Links
+	}
+
+	// child で規則が指定された要素と keep の要素は parent の規則を引き継がない
+	overridden := make(map[string]bool)
+	for _, tagName := range child.Keep {
+		overridden[tagName] = true
+	}
+	for _, tagName := range child.Drop {
+		overridden[tagName] = true
+	}
+	for _, tagName := range child.Unwrap {
+		overridden[tagName] = true
+	}
+	for tagName := range child.Rename {
+		overridden[tagName] = true
+	}
+
+	inherit := func(parentNames []string, childNames []string) []string {
+		var names []string
+		for _, tagName := range parentNames {
+			if !overridden[tagName] {
+				names = append(names, tagName)
+			}
+		}
+		return append(names, childNames...)
+	}
+	merged.Drop = inherit(parent.Drop, child.Drop)
+	merged.Unwrap = inherit(parent.Unwrap, child.Unwrap)
+
+	if len(parent.Rename) != 0 || len(child.Rename) != 0 {
+		merged.Rename = make(map[string]string)
+		for from, to := range parent.Rename {
+			if !overridden[from] {
+				merged.Rename[from] = to
+			}
+		}
+		for from, to := range child.Rename {
+			merged.Rename[from] = to
+		}
+	}
+
+	if parent.Attributes != nil || child.Attributes != nil {
+		merged.Attributes = make(map[string][]string)
+		for tagName, attrs := range parent.Attributes {
+			merged.Attributes[tagName] = attrs
+		}
+		for tagName, attrs := range child.Attributes {
+			merged.Attributes[tagName] = attrs
+		}
+	}
+
+	return merged
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
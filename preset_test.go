@@ -0,0 +1,122 @@
+package html2html
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresetRegistry(t *testing.T) {
+	registry := NewPresetRegistry()
+	registry.Register("base", &Config{
+		Drop:   []string{"script", "iframe"},
+		Rename: map[string]string{"b": "strong"},
+		Attributes: map[string][]string{
+			"a": {"href"},
+		},
+	})
+	registry.Register("child", &Config{
+		Extends: "base",
+		Keep:    []string{"iframe"},
+		Lenient: boolPtr(true),
+		Rename:  map[string]string{"i": "em"},
+		Attributes: map[string][]string{
+			"iframe": {"src"},
+		},
+	})
+
+	conv, err := registry.NewConverter("child")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	html := `<script>x</script><iframe src="/embed" onload="x()"></iframe><a href="/" target="_blank"><b>b</b><i>i`
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<iframe src="/embed"></iframe><a href="/"><strong>b</strong><em>i</em></a>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+
+	if names := registry.Names(); len(names) != 2 || names[0] != "base" || names[1] != "child" {
+		t.Error("unexpected", names)
+	}
+}
+
+func TestPresetRegistry_errors(t *testing.T) {
+	registry := NewPresetRegistry()
+	registry.Register("a", &Config{Extends: "b"})
+	registry.Register("b", &Config{Extends: "a"})
+	registry.Register("c", &Config{Extends: "unknown"})
+
+	if _, err := registry.Lookup("a"); err == nil || err.Error() != `preset "a" extends itself` {
+		t.Error("unexpected", err)
+	}
+	if _, err := registry.Lookup("c"); err == nil || err.Error() != `unknown preset "unknown"` {
+		t.Error("unexpected", err)
+	}
+}
+
+func TestDefaultPresets(t *testing.T) {
+	for _, name := range []string{"default", "safe", "comment", "article", "amp", "email"} {
+		if _, err := DefaultPresets.NewConverter(name); err != nil {
+			t.Error(name, err)
+		}
+	}
+
+	conv, err := DefaultPresets.NewConverter("amp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.Convert(strings.NewReader(`<p class="lead">Hi!<img src="a.png" onerror="x()"><script>x</script></p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `<p class="lead">Hi!<amp-img src="a.png"></amp-img></p>`; result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestPresetRegistry_lenientOverride(t *testing.T) {
+	registry := NewPresetRegistry()
+	registry.Register("base", &Config{Lenient: boolPtr(true)})
+	registry.Register("strict", &Config{Extends: "base", Lenient: boolPtr(false)})
+	registry.Register("inherit", &Config{Extends: "base"})
+
+	for name, expected := range map[string]bool{"strict": false, "inherit": true} {
+		cfg, err := registry.Lookup(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.Lenient == nil || *cfg.Lenient != expected {
+			t.Error("unexpected", name, cfg.Lenient)
+		}
+	}
+
+	conv, err := registry.NewConverter("strict")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conv.Convert(strings.NewReader(`<p><i>x</b></p>`)); err == nil {
+		t.Error("expected error")
+	}
+}
+
+func TestDefaultPresets_links(t *testing.T) {
+	conv, err := DefaultPresets.NewConverter("comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := conv.Convert(strings.NewReader(`<a href="https://example.com/" onclick="evil()">Hi!</a><a href="/local">local</a><a href="javascript:alert(1)">js</a>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<a href="https://example.com/" rel="nofollow ugc noopener" target="_blank">Hi!</a><a href="/local">local</a>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
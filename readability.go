@@ -0,0 +1,447 @@
+package html2html
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrArticleNotFound は本文らしい要素が見つからなかった
+var ErrArticleNotFound = errors.New("article content not found")
+
+var (
+	readabilityUnlikelyPattern = regexp.MustCompile(`(?i)\b(?:ad|ads|advert\w*|banner|breadcrumbs?|combx|comment\w*|community|cookie\w*|disqus|extra|footer|header|legends?|menu|modal|nav\w*|newsletter|pager|pagination|popup|promo\w*|related|remark|replies|rss|share\w*|shoutbox|sidebar|skyscraper|social\w*|sponsor\w*|subscribe|tags?|tool(?:bar|s)|widget\w*)\b|\bad-|-ad\b`)
+	readabilityMaybePattern    = regexp.MustCompile(`(?i)\b(?:and|article\w*|body|column|content|main|shadow)\b`)
+	readabilityPositivePattern = regexp.MustCompile(`(?i)\b(?:article\w*|blog|body|content|entry|h-entry|hentry|main|page|post\w*|story|text)\b`)
+	readabilityNegativePattern = regexp.MustCompile(`(?i)\b(?:ad|ads|banner|combx|comment\w*|com-|contact|footer|footnote|masthead|media|meta|outbrain|promo\w*|related|scroll|share\w*|shoutbox|sidebar|skyscraper|sponsor\w*|shopping|tags?|tool|widget\w*)\b`)
+	readabilityBylinePattern   = regexp.MustCompile(`(?i)\b(?:byline|author|dateline|writtenby|p-author)\b`)
+	readabilityTitleSeparator  = regexp.MustCompile(`\s+[|\-–—/»:]\s+`)
+)
+
+// ReadabilityBoilerplateElements は本文を探す前に取り除く要素
+var ReadabilityBoilerplateElements = []string{"script", "style", "noscript", "template", "nav", "footer", "aside", "form", "button", "select", "iframe", "object", "embed", "svg", "header"}
+
+// Article は Readability が取り出した本文と、そのメタデータ
+type Article struct {
+	// Content は本文を囲む div。元の木とは別の Tag
+	Content       Tag
+	Title         string
+	Byline        string
+	LeadImage     string
+	PublishedTime string
+}
+
+// Readability は記事のページから本文を取り出す。
+// 段落の文字数と読点の数を祖先の要素に加点し、リンクの割合と class, id から本文らしさを決める。
+type Readability struct {
+	// MinParagraphLength より短い段落は点数を付けない
+	MinParagraphLength int
+	// Boilerplate の要素と、class か id が UnlikelyPattern に合い MaybePattern に合わない要素は最初に取り除く
+	Boilerplate     []string
+	UnlikelyPattern *regexp.Regexp
+	MaybePattern    *regexp.Regexp
+	// PositivePattern と NegativePattern に合う class, id はそれぞれ加点、減点する
+	PositivePattern *regexp.Regexp
+	NegativePattern *regexp.Regexp
+}
+
+func NewReadability() *Readability {
+	return &Readability{
+		MinParagraphLength: 25,
+		Boilerplate:        ReadabilityBoilerplateElements,
+		UnlikelyPattern:    readabilityUnlikelyPattern,
+		MaybePattern:       readabilityMaybePattern,
+		PositivePattern:    readabilityPositivePattern,
+		NegativePattern:    readabilityNegativePattern,
+	}
+}
+
+// Extract は root から本文とメタデータを取り出す。root は書き換えない
+func (readability *Readability) Extract(root Tag) (*Article, error) {
+	article := &Article{
+		Title:         articleTitle(root),
+		Byline:        articleByline(root),
+		LeadImage:     firstNonEmpty(metaContent(root, "og:image"), metaContent(root, "twitter:image")),
+		PublishedTime: articlePublishedTime(root),
+	}
+
+	doc := cloneToken(root).Tag()
+	readability.removeBoilerplate(doc)
+
+	top, scores := readability.topCandidate(doc)
+	if top == nil {
+		return nil, ErrArticleNotFound
+	}
+
+	content := CreateElement("div")
+	for _, token := range readability.withSiblings(top, scores) {
+		if token.Parent() != nil {
+			token.Parent().RemoveChildToken(token)
+		}
+		content.AddChildTokens(token)
+	}
+	readability.clean(content)
+	if readabilityTextLength(content) == 0 {
+		return nil, ErrArticleNotFound
+	}
+	article.Content = content
+
+	if article.LeadImage == "" {
+		for _, img := range content.GetElementsByTagName("img") {
+			if src := img.GetAttr("src"); src != nil && src.Value != "" {
+				article.LeadImage = src.Value
+				break
+			}
+		}
+	}
+
+	return article, nil
+}
+
+// removeBoilerplate は本文ではなさそうな要素を取り除く
+func (readability *Readability) removeBoilerplate(tag Tag) {
+	for _, token := range append([]Token(nil), tag.Tokens()...) {
+		if token.Type() == TypeCommentToken {
+			tag.RemoveChildToken(token)
+			continue
+		}
+		if token.Type() != TypeTagToken {
+			continue
+		}
+
+		child := token.Tag()
+		name := strings.ToLower(child.Name())
+		hints := classAndID(child)
+		switch {
+		case containsString(readability.Boilerplate, name),
+			name != "html" && name != "body" && name != "article" && name != "main" &&
+				readability.UnlikelyPattern.MatchString(hints) && !readability.MaybePattern.MatchString(hints),
+			child.HasAttrValueCaseInsensitive("role", "navigation") || child.HasAttrValueCaseInsensitive("role", "complementary"),
+			child.HasAttr("hidden") || child.HasAttrValueCaseInsensitive("aria-hidden", "true"):
+			tag.RemoveChildToken(token)
+		default:
+			readability.removeBoilerplate(child)
+		}
+	}
+}
+
+// topCandidate は段落の点数を祖先に配り、リンクの割合で補正した点数が最も高い要素を返す
+func (readability *Readability) topCandidate(doc Tag) (Tag, map[Tag]float64) {
+	scores := make(map[Tag]float64)
+	var candidates []Tag
+	addScore := func(tag Tag, score float64) {
+		if _, ok := scores[tag]; !ok {
+			scores[tag] = readability.initialScore(tag)
+			candidates = append(candidates, tag)
+		}
+		scores[tag] += score
+	}
+
+	for _, name := range []string{"p", "pre", "td", "blockquote", "li"} {
+		for _, paragraph := range doc.GetElementsByTagName(name) {
+			text := collapseSpace(strings.TrimSpace(paragraph.TextContent()))
+			length := utf8.RuneCountInString(text)
+			if length < readability.MinParagraphLength {
+				continue
+			}
+
+			score := 1 + float64(strings.Count(text, ",")+strings.Count(text, "、")+strings.Count(text, "，"))
+			if bonus := float64(length / 100); bonus < 3 {
+				score += bonus
+			} else {
+				score += 3
+			}
+
+			// 親に全て、祖父母に半分、それより上は距離に応じて少なく配る
+			ancestor := paragraph.Parent()
+			for level := 0; ancestor != nil && !ancestor.IsDocumentRoot() && level < 5; level++ {
+				switch level {
+				case 0:
+					addScore(ancestor, score)
+				case 1:
+					addScore(ancestor, score/2)
+				default:
+					addScore(ancestor, score/float64(level*3))
+				}
+				ancestor = ancestor.Parent()
+			}
+		}
+	}
+
+	var top Tag
+	topScore := 0.0
+	for _, candidate := range candidates {
+		score := scores[candidate] * (1 - linkDensity(candidate))
+		scores[candidate] = score
+		if top == nil || score > topScore {
+			top, topScore = candidate, score
+		}
+	}
+	if top == nil {
+		if bodies := doc.GetElementsByTagName("body"); len(bodies) != 0 {
+			return bodies[0], scores
+		}
+		return nil, scores
+	}
+
+	return top, scores
+}
+
+// withSiblings は top と、同じ親の中で本文の続きらしい兄弟を返す
+func (readability *Readability) withSiblings(top Tag, scores map[Tag]float64) []Token {
+	parent := top.Parent()
+	if parent == nil || parent.IsDocumentRoot() || strings.EqualFold(parent.Name(), "html") {
+		return []Token{top}
+	}
+
+	threshold := scores[top] * 0.2
+	if threshold < 10 {
+		threshold = 10
+	}
+	topHints := top.GetAttr("class")
+
+	var result []Token
+	for _, token := range parent.Tokens() {
+		if token == Token(top) {
+			result = append(result, token)
+			continue
+		}
+		if token.Type() != TypeTagToken {
+			continue
+		}
+
+		sibling := token.Tag()
+		score, scored := scores[sibling]
+		if topHints != nil && topHints.Value != "" && sibling.HasAttrValue("class", topHints.Value) {
+			score += scores[top] * 0.2
+		}
+		switch {
+		case scored && score >= threshold:
+			result = append(result, token)
+		case strings.EqualFold(sibling.Name(), "p"):
+			length := readabilityTextLength(sibling)
+			density := linkDensity(sibling)
+			if (length > 80 && density < 0.25) || (length > 0 && density == 0 && strings.ContainsAny(sibling.TextContent(), ".。")) {
+				result = append(result, token)
+			}
+		}
+	}
+
+	return result
+}
+
+// clean は本文の中のリンク集や空の段落を取り除く
+func (readability *Readability) clean(tag Tag) {
+	for _, token := range append([]Token(nil), tag.Tokens()...) {
+		if token.Type() != TypeTagToken {
+			continue
+		}
+
+		child := token.Tag()
+		readability.clean(child)
+
+		name := strings.ToLower(child.Name())
+		length := readabilityTextLength(child)
+		images := len(child.GetElementsByTagName("img"))
+		switch {
+		case name == "p" && length == 0 && images == 0 && len(child.GetElementsByTagName("br")) == 0:
+			tag.RemoveChildToken(token)
+		case name == "div" || name == "section" || name == "ul" || name == "ol" || name == "table":
+			if images == 0 && length < 200 && linkDensity(child) > 0.5 {
+				tag.RemoveChildToken(token)
+			} else if readability.classWeight(child) < 0 && length < 200 {
+				tag.RemoveChildToken(token)
+			}
+		}
+	}
+}
+
+func (readability *Readability) initialScore(tag Tag) float64 {
+	score := readability.classWeight(tag)
+	switch strings.ToLower(tag.Name()) {
+	case "div", "article", "main":
+		score += 5
+	case "pre", "td", "blockquote":
+		score += 3
+	case "address", "ol", "ul", "dl", "dd", "dt", "li", "form":
+		score -= 3
+	case "h1", "h2", "h3", "h4", "h5", "h6", "th":
+		score -= 5
+	}
+
+	return score
+}
+
+func (readability *Readability) classWeight(tag Tag) float64 {
+	weight := 0.0
+	for _, key := range []string{"class", "id"} {
+		attr := tag.GetAttr(key)
+		if attr == nil || attr.Value == "" {
+			continue
+		}
+		if readability.NegativePattern.MatchString(attr.Value) {
+			weight -= 25
+		}
+		if readability.PositivePattern.MatchString(attr.Value) {
+			weight += 25
+		}
+	}
+
+	return weight
+}
+
+// linkDensity は tag の文字数のうち a の中の文字数の割合を返す
+func linkDensity(tag Tag) float64 {
+	length := readabilityTextLength(tag)
+	if length == 0 {
+		return 0
+	}
+
+	linkLength := 0
+	for _, a := range tag.GetElementsByTagName("a") {
+		linkLength += readabilityTextLength(a)
+	}
+
+	return float64(linkLength) / float64(length)
+}
+
+func readabilityTextLength(tag Tag) int {
+	return utf8.RuneCountInString(strings.TrimSpace(collapseSpace(tag.TextContent())))
+}
+
+func classAndID(tag Tag) string {
+	var hints []string
+	for _, key := range []string{"class", "id"} {
+		if attr := tag.GetAttr(key); attr != nil {
+			hints = append(hints, attr.Value)
+		}
+	}
+
+	return strings.Join(hints, " ")
+}
+
+// articleTitle は og:title, ページの title から本文の題名を決める。title の末尾のサイト名は取り除く
+func articleTitle(root Tag) string {
+	if title := metaContent(root, "og:title"); title != "" {
+		return title
+	}
+
+	var title string
+	if titles := root.GetElementsByTagName("title"); len(titles) != 0 {
+		title = strings.TrimSpace(collapseSpace(titles[0].TextContent()))
+	}
+	if loc := readabilityTitleSeparator.FindAllStringIndex(title, -1); len(loc) != 0 {
+		// 区切りの前が3語以上あればサイト名を取り除く
+		if head := title[:loc[len(loc)-1][0]]; len(strings.Fields(head)) >= 3 || utf8.RuneCountInString(head) >= 10 {
+			title = head
+		}
+	}
+	if title == "" {
+		if h1s := root.GetElementsByTagName("h1"); len(h1s) == 1 {
+			title = strings.TrimSpace(collapseSpace(h1s[0].TextContent()))
+		}
+	}
+
+	return title
+}
+
+func articleByline(root Tag) string {
+	if author := metaContent(root, "author", "article:author"); author != "" {
+		return author
+	}
+
+	var byline string
+	var visit func(tag Tag) bool
+	visit = func(tag Tag) bool {
+		for _, token := range tag.Tokens() {
+			if token.Type() != TypeTagToken {
+				continue
+			}
+			child := token.Tag()
+			if child.HasAttrValueCaseInsensitive("rel", "author") || child.HasAttrValueCaseInsensitive("itemprop", "author") ||
+				readabilityBylinePattern.MatchString(classAndID(child)) {
+				text := strings.TrimSpace(collapseSpace(child.TextContent()))
+				if text != "" && utf8.RuneCountInString(text) < 100 {
+					byline = text
+					return true
+				}
+			}
+			if visit(child) {
+				return true
+			}
+		}
+		return false
+	}
+	visit(root)
+
+	return byline
+}
+
+func articlePublishedTime(root Tag) string {
+	if published := metaContent(root, "article:published_time", "datePublished", "pubdate", "publishdate", "date", "dc.date"); published != "" {
+		return published
+	}
+
+	for _, tag := range root.GetElementsByTagName("time") {
+		if attr := tag.GetAttr("datetime"); attr != nil && attr.Value != "" {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// metaContent は name か property か itemprop が names のいずれかに合う最初の meta の content を返す
+func metaContent(root Tag, names ...string) string {
+	for _, name := range names {
+		for _, meta := range root.GetElementsByTagName("meta") {
+			if !meta.HasAttrValueCaseInsensitive("name", name) && !meta.HasAttrValueCaseInsensitive("property", name) &&
+				!meta.HasAttrValueCaseInsensitive("itemprop", name) {
+				continue
+			}
+			if content := meta.GetAttr("content"); content != nil && strings.TrimSpace(content.Value) != "" {
+				return strings.TrimSpace(content.Value)
+			}
+		}
+	}
+
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// cloneToken は token とその子孫を複製する
+func cloneToken(token Token) Token {
+	if token.Type() != TypeTagToken {
+		return &textTokenImpl{tokenType: token.Type(), text: token.TextToken().Text()}
+	}
+
+	tag := token.Tag()
+	var clone Tag
+	switch {
+	case tag.IsDocumentRoot():
+		clone = CreateDocumentRoot()
+	case tag.IsSelfClosing():
+		clone = CreateElementSelfClosing(tag.Name())
+	default:
+		clone = CreateElement(tag.Name())
+	}
+	for _, attr := range tag.Attrs() {
+		copied := *attr
+		clone.SetAttrs(append(clone.Attrs(), &copied))
+	}
+	for _, child := range tag.Tokens() {
+		clone.AddChildTokens(cloneToken(child))
+	}
+
+	return clone
+}
@@ -0,0 +1,137 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const readabilityTestPage = `<!DOCTYPE html>
+<html>
+<head>
+<title>How to brew coffee at home | Example News</title>
+<meta name="author" content="Jane Doe">
+<meta property="article:published_time" content="2016-05-01T09:00:00Z">
+<meta property="og:image" content="https://example.com/lead.jpg">
+</head>
+<body>
+<nav><a href="/">Home</a> <a href="/news">News</a></nav>
+<div class="sidebar"><p>Popular posts, trending now, and more things you may like to read today.</p></div>
+<div id="main">
+<div class="article-body">
+<h1>How to brew coffee at home</h1>
+<p>Brewing coffee at home is easy, cheap, and rewarding once you know the basics of grind size, water temperature and timing.</p>
+<p>Start with fresh beans, grind them just before brewing, and use water that is just off the boil for the best extraction.</p>
+<p>Finally, experiment with ratios, because taste is personal, and the perfect cup is the one you enjoy the most.</p>
+<div class="share-buttons"><a href="#">Twitter</a> <a href="#">Facebook</a></div>
+<p></p>
+</div>
+<div class="ad-banner"><p>Buy the best coffee machine today, limited offer, free shipping worldwide.</p></div>
+</div>
+<footer><p>Copyright Example News, all rights reserved, terms and privacy policy apply.</p></footer>
+<script>track();</script>
+</body>
+</html>`
+
+func TestReadability_Extract(t *testing.T) {
+	root, err := NewConverter().Parse(strings.NewReader(readabilityTestPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := bytes.NewBufferString("")
+	root.BuildHTML(before)
+
+	article, err := NewReadability().Extract(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if article.Title != "How to brew coffee at home" {
+		t.Error("unexpected", article.Title)
+	}
+	if article.Byline != "Jane Doe" {
+		t.Error("unexpected", article.Byline)
+	}
+	if article.LeadImage != "https://example.com/lead.jpg" {
+		t.Error("unexpected", article.LeadImage)
+	}
+	if article.PublishedTime != "2016-05-01T09:00:00Z" {
+		t.Error("unexpected", article.PublishedTime)
+	}
+
+	buf := bytes.NewBufferString("")
+	article.Content.BuildHTML(buf)
+	result := buf.String()
+	for _, text := range []string{"Brewing coffee at home", "Start with fresh beans", "experiment with ratios"} {
+		if !strings.Contains(result, text) {
+			t.Error("missing", text, result)
+		}
+	}
+	for _, text := range []string{"Home", "Popular posts", "Twitter", "Buy the best", "Copyright", "track()", "<p></p>"} {
+		if strings.Contains(result, text) {
+			t.Error("unexpected", text, result)
+		}
+	}
+
+	after := bytes.NewBufferString("")
+	root.BuildHTML(after)
+	if before.String() != after.String() {
+		t.Error("root is modified")
+	}
+}
+
+func TestReadability_ExtractFallback(t *testing.T) {
+	html := `<html><head><title>Short</title></head><body>
+<div class="post"><h1>Release notes</h1>
+<span class="byline">By John Smith</span>
+<time datetime="2017-01-02">Jan 2</time>
+<img src="/figure.png">
+<p>This release fixes several bugs, improves performance, and adds a new configuration option.</p>
+</div></body></html>`
+
+	root, err := NewConverter().Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	article, err := NewReadability().Extract(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if article.Title != "Short" {
+		t.Error("unexpected", article.Title)
+	}
+	if article.Byline != "By John Smith" {
+		t.Error("unexpected", article.Byline)
+	}
+	if article.PublishedTime != "2017-01-02" {
+		t.Error("unexpected", article.PublishedTime)
+	}
+	if article.LeadImage != "/figure.png" {
+		t.Error("unexpected", article.LeadImage)
+	}
+}
+
+func TestReadability_ExtractNotFound(t *testing.T) {
+	root, err := NewConverter().Parse(strings.NewReader(`<nav><a href="/">Home</a></nav><script>x()</script>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewReadability().Extract(root)
+	if err != ErrArticleNotFound {
+		t.Error("unexpected", err)
+	}
+}
+
+func TestLinkDensity(t *testing.T) {
+	tag := CreateElement("div")
+	tag.AddText("abcd")
+	a := CreateElement("a")
+	a.AddText("efgh")
+	tag.AddChildTokens(a)
+
+	if density := linkDensity(tag); density != 0.5 {
+		t.Error("unexpected", density)
+	}
+}
@@ -0,0 +1,181 @@
+package html2html
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+var _ TokenConsumer = &HeadingAnchorConsumer{}
+
+// HeadingElements は見出しの要素名
+var HeadingElements = []string{"h1", "h2", "h3", "h4", "h5", "h6"}
+
+// TOCEntry は目次の1項目
+type TOCEntry struct {
+	Level    int
+	ID       string
+	Text     string
+	Children []*TOCEntry
+}
+
+// HeadingAnchorConsumer は見出しに重複しない id を付け、目次を組み立てるTokenConsumer。
+// Register で h1〜h6 に設定して使う。文書ごとに作ること。
+type HeadingAnchorConsumer struct {
+	conv Converter
+
+	// AnchorLink が設定されていれば見出しの先頭に追加する
+	AnchorLink func(id string) Token
+	// Slugify は見出しの文字列から id を作る。デフォルトは Slugify
+	Slugify func(text string) string
+
+	ids     map[string]bool
+	entries []*TOCEntry
+}
+
+func NewHeadingAnchorConsumer(conv Converter) *HeadingAnchorConsumer {
+	return &HeadingAnchorConsumer{
+		conv:    conv,
+		Slugify: Slugify,
+		ids:     make(map[string]bool),
+	}
+}
+
+// Register は h1〜h6 にこのTokenConsumerを設定する
+func (consumer *HeadingAnchorConsumer) Register() {
+	for _, tagName := range HeadingElements {
+		consumer.conv.SetTagNameConsumer(tagName, consumer)
+	}
+}
+
+func (consumer *HeadingAnchorConsumer) ConsumeToken(parent Tag, tokenizer *html.Tokenizer, token html.Token) (html.Token, error) {
+	tmp, token, err := consumeElement(consumer.conv, parent, tokenizer, token)
+	if err != nil && err != io.EOF {
+		return token, err
+	}
+
+	for _, child := range tmp.Tokens() {
+		if child.Type() == TypeTagToken && headingLevel(child.Tag()) != 0 {
+			consumer.AddHeading(child.Tag())
+		}
+	}
+	parent.AddChildTokens(tmp.Tokens()...)
+
+	return token, err
+}
+
+// AddHeading は見出し tag に id を付けて目次に追加する。既に id があればそれを使う
+func (consumer *HeadingAnchorConsumer) AddHeading(tag Tag) {
+	text := strings.Join(strings.Fields(tag.TextContent()), " ")
+
+	var id string
+	if attr := tag.GetAttr("id"); attr != nil && attr.Value != "" {
+		id = attr.Value
+		consumer.ids[id] = true
+	} else {
+		id = consumer.uniqueID(consumer.Slugify(text))
+		tag.AddAttr("id", id)
+	}
+
+	if consumer.AnchorLink != nil {
+		tag.UnshiftChileToken(consumer.AnchorLink(id))
+	}
+
+	consumer.entries = append(consumer.entries, &TOCEntry{
+		Level: headingLevel(tag),
+		ID:    id,
+		Text:  text,
+	})
+}
+
+// TOC は見出しのレベルに従って入れ子にした目次を返す
+func (consumer *HeadingAnchorConsumer) TOC() []*TOCEntry {
+	var root []*TOCEntry
+	var stack []*TOCEntry
+	for _, entry := range consumer.entries {
+		entry := &TOCEntry{Level: entry.Level, ID: entry.ID, Text: entry.Text}
+		for len(stack) != 0 && stack[len(stack)-1].Level >= entry.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			root = append(root, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+	}
+
+	return root
+}
+
+func (consumer *HeadingAnchorConsumer) uniqueID(slug string) string {
+	id := slug
+	for i := 1; consumer.ids[id]; i++ {
+		id = fmt.Sprintf("%s-%d", slug, i)
+	}
+	consumer.ids[id] = true
+
+	return id
+}
+
+// BuildTOCTag は目次を <ol> の入れ子にする
+func BuildTOCTag(entries []*TOCEntry) Tag {
+	ol := CreateElement("ol")
+	for _, entry := range entries {
+		li := CreateElement("li")
+		a := CreateElement("a")
+		a.AddAttr("href", "#"+entry.ID)
+		a.AddText(entry.Text)
+		li.AddChildTokens(a)
+		if len(entry.Children) != 0 {
+			li.AddChildTokens(BuildTOCTag(entry.Children))
+		}
+		ol.AddChildTokens(li)
+	}
+
+	return ol
+}
+
+// Slugify は text を id に使える文字列にする。
+// 文字(日本語を含む)と数字は残し、空白は "-" にし、記号は取り除く。全角英数字は半角にする。
+func Slugify(text string) string {
+	var runes []rune
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if r >= 0xff01 && r <= 0xff5e {
+			// 全角英数字記号
+			r = unicode.ToLower(r - 0xfee0)
+		}
+
+		switch {
+		case unicode.IsLetter(r), unicode.IsNumber(r), unicode.IsMark(r), r == '_':
+			if dash && len(runes) != 0 {
+				runes = append(runes, '-')
+			}
+			dash = false
+			runes = append(runes, r)
+		case unicode.IsSpace(r), r == '-':
+			dash = true
+		}
+	}
+
+	if len(runes) == 0 {
+		return "section"
+	}
+
+	return string(runes)
+}
+
+func headingLevel(tag Tag) int {
+	for idx, tagName := range HeadingElements {
+		if tag.Name() == tagName {
+			return idx + 1
+		}
+	}
+
+	return 0
+}
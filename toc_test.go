@@ -0,0 +1,76 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHeadingAnchorConsumer(t *testing.T) {
+	html := `<h1>Getting Started</h1><h2>Install</h2><h2 id="usage">Usage</h2><h3>日本語の見出し！</h3><h2>Install</h2><h1>ＡＰＩ Reference</h1>`
+
+	conv := NewConverter()
+	consumer := NewHeadingAnchorConsumer(conv)
+	consumer.AnchorLink = func(id string) Token {
+		a := CreateElement("a")
+		a.AddAttr("href", "#"+id)
+		a.AddText("#")
+		return a
+	}
+	consumer.Register()
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<h1 id="getting-started"><a href="#getting-started">#</a>Getting Started</h1><h2 id="install"><a href="#install">#</a>Install</h2><h2 id="usage"><a href="#usage">#</a>Usage</h2><h3 id="日本語の見出し"><a href="#日本語の見出し">#</a>日本語の見出し！</h3><h2 id="install-1"><a href="#install-1">#</a>Install</h2><h1 id="api-reference"><a href="#api-reference">#</a>ＡＰＩ Reference</h1>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+
+	buf := bytes.NewBufferString("")
+	BuildTOCTag(consumer.TOC()).BuildHTML(buf)
+	expected = `<ol><li><a href="#getting-started">Getting Started</a><ol><li><a href="#install">Install</a></li><li><a href="#usage">Usage</a><ol><li><a href="#日本語の見出し">日本語の見出し！</a></li></ol></li><li><a href="#install-1">Install</a></li></ol></li><li><a href="#api-reference">ＡＰＩ Reference</a></li></ol>`
+	if v := buf.String(); v != expected {
+		t.Log("expected:\n", expected, "actual:\n", v)
+		t.Fail()
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	for text, expected := range map[string]string{
+		"Hello, World!":     "hello-world",
+		"  foo -- bar  ":    "foo-bar",
+		"Go 1.8 の新機能":       "go-18-の新機能",
+		"「かっこ」と、句読点。":       "かっこと句読点",
+		"!!!":               "section",
+		"snake_case_string": "snake_case_string",
+	} {
+		if v := Slugify(text); v != expected {
+			t.Error("unexpected", text, v)
+		}
+	}
+}
+
+func TestBuildTOCTag_entity(t *testing.T) {
+	conv := NewConverter()
+	consumer := NewHeadingAnchorConsumer(conv)
+	consumer.Register()
+	if _, err := conv.Convert(strings.NewReader(`<h2>A &amp; B</h2>`)); err != nil {
+		t.Fatal(err)
+	}
+
+	toc := BuildTOCTag(consumer.TOC())
+	if v := toc.TextContent(); v != "A & B" {
+		t.Error("unexpected", v)
+	}
+
+	buf := bytes.NewBufferString("")
+	toc.BuildHTMLWithOptions(buf, &BuildOptions{Entities: EntityMinimal})
+	expected := `<ol><li><a href="#a-b">A &amp; B</a></li></ol>`
+	if v := buf.String(); v != expected {
+		t.Log("expected:\n", expected, "actual:\n", v)
+		t.Fail()
+	}
+}
@@ -0,0 +1,189 @@
+package html2html
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TruncateOptions は Truncate で残す長さを指定する。MaxChars と MaxWords の両方が指定された場合は先に達した方で切る。
+type TruncateOptions struct {
+	// MaxChars は残す文字数(書記素クラスタ単位)。連続する空白は1文字と数える。ブロック要素の境界は空白として扱う
+	MaxChars int
+	// MaxWords は残す単語数
+	MaxWords int
+	// Ellipsis は切り詰めた箇所の直後に追加する文字列
+	Ellipsis string
+	// ReadMore が設定されていれば切り詰めた場合に tag の末尾に追加する
+	ReadMore func() Token
+}
+
+func NewTruncateOptions(maxChars int) *TruncateOptions {
+	return &TruncateOptions{MaxChars: maxChars, Ellipsis: "…"}
+}
+
+// Truncate は tag 以下のテキストを opts の長さまで切り詰め、切り詰めたかどうかを返す。
+// 切り詰めた後も要素は全て閉じられ、中身が無くなった要素は取り除かれる。
+func Truncate(tag Tag, opts *TruncateOptions) bool {
+	tr := &truncater{opts: opts, inSpace: true}
+	if !tr.walk(tag) {
+		return false
+	}
+
+	if opts.Ellipsis != "" {
+		lastElement(tag).AddText(opts.Ellipsis)
+	}
+	if opts.ReadMore != nil {
+		tag.AddChildTokens(opts.ReadMore())
+	}
+
+	return true
+}
+
+type truncater struct {
+	opts *TruncateOptions
+
+	chars   int
+	words   int
+	inSpace bool
+}
+
+func (tr *truncater) walk(tag Tag) bool {
+	tokens := tag.Tokens()
+	for idx, token := range tokens {
+		switch token.Type() {
+		case TypeTextToken:
+			if tag.Name() == "script" || tag.Name() == "style" {
+				continue
+			}
+
+			text := token.TextToken().Text()
+			n, cut := tr.consume(text)
+			if !cut {
+				continue
+			}
+
+			kept := make([]Token, 0, idx+1)
+			kept = append(kept, tokens[:idx]...)
+			if text = strings.TrimRightFunc(text[:n], unicode.IsSpace); text != "" {
+				kept = append(kept, CreateTextToken(text))
+			}
+			tag.SetTokens(kept)
+			return true
+
+		case TypeTagToken:
+			child := token.Tag()
+			if IsBlockElement(child) {
+				tr.inSpace = true
+			}
+			if !tr.walk(child) {
+				if IsBlockElement(child) {
+					tr.inSpace = true
+				}
+				continue
+			}
+
+			kept := make([]Token, 0, idx+1)
+			kept = append(kept, tokens[:idx]...)
+			if len(child.Tokens()) != 0 || IsVoidElement(child) {
+				kept = append(kept, child)
+			}
+			tag.SetTokens(kept)
+			return true
+		}
+	}
+
+	return false
+}
+
+// consume は text を読み進め、上限を超えた場合はそこまでのバイト数と true を返す
+func (tr *truncater) consume(text string) (int, bool) {
+	for pos := 0; pos < len(text); {
+		next := nextGraphemeBoundary(text, pos)
+		r, _ := utf8.DecodeRuneInString(text[pos:])
+
+		if unicode.IsSpace(r) {
+			if !tr.inSpace {
+				tr.chars++
+			}
+			tr.inSpace = true
+		} else {
+			if tr.inSpace {
+				tr.words++
+			}
+			tr.chars++
+			tr.inSpace = false
+		}
+
+		if tr.opts.MaxChars > 0 && tr.chars > tr.opts.MaxChars {
+			return pos, true
+		}
+		if tr.opts.MaxWords > 0 && tr.words > tr.opts.MaxWords {
+			return pos, true
+		}
+
+		pos = next
+	}
+
+	return len(text), false
+}
+
+// lastElement は tag の末尾を辿って一番内側の(void要素ではない)要素を返す
+func lastElement(tag Tag) Tag {
+	for {
+		tokens := tag.Tokens()
+		if len(tokens) == 0 {
+			return tag
+		}
+		last := tokens[len(tokens)-1]
+		if last.Type() != TypeTagToken || IsVoidElement(last.Tag()) {
+			return tag
+		}
+		tag = last.Tag()
+	}
+}
+
+// nextGraphemeBoundary は s[pos:] から始まる書記素クラスタの次の位置を返す。
+// 結合文字、異体字セレクタ、ZWJ で繋がった絵文字、国旗(regional indicator の組)、CRLF を1つとして扱う。
+func nextGraphemeBoundary(s string, pos int) int {
+	r, size := utf8.DecodeRuneInString(s[pos:])
+	pos += size
+
+	if r == '\r' {
+		if pos < len(s) && s[pos] == '\n' {
+			pos++
+		}
+		return pos
+	}
+
+	if isRegionalIndicator(r) {
+		if next, size := utf8.DecodeRuneInString(s[pos:]); isRegionalIndicator(next) {
+			pos += size
+		}
+	}
+
+	for pos < len(s) {
+		next, size := utf8.DecodeRuneInString(s[pos:])
+		switch {
+		case next == '\u200d': // ZWJ
+			pos += size
+			if pos < len(s) {
+				_, size = utf8.DecodeRuneInString(s[pos:])
+				pos += size
+			}
+		case unicode.In(next, unicode.Mn, unicode.Me, unicode.Mc),
+			unicode.Is(unicode.Variation_Selector, next),
+			next >= 0x1f3fb && next <= 0x1f3ff, // emoji modifier
+			next >= 0xe0020 && next <= 0xe007f: // tag
+			pos += size
+		default:
+			return pos
+		}
+	}
+
+	return pos
+}
+
+func isRegionalIndicator(r rune) bool {
+	return r >= 0x1f1e6 && r <= 0x1f1ff
+}
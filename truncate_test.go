@@ -0,0 +1,81 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTruncate(t *testing.T) {
+	html := `<p>Hello, <b>wonderful</b> world!</p><p>Second paragraph</p>`
+	r := strings.NewReader(html)
+	tag, err := NewConverter().Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !Truncate(tag, NewTruncateOptions(12)) {
+		t.Fatal("not truncated")
+	}
+
+	buf := bytes.NewBufferString("")
+	tag.BuildHTML(buf)
+	if v := buf.String(); v != `<p>Hello, <b>wonde…</b></p>` {
+		t.Error("unexpected", v)
+	}
+}
+
+func TestTruncate_words(t *testing.T) {
+	html := `<div><p>one two</p><p><i>three</i> four</p><img src="a.png"></div>`
+	r := strings.NewReader(html)
+	tag, err := NewConverter().Parse(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := &TruncateOptions{
+		MaxWords: 2,
+		ReadMore: func() Token {
+			a := CreateElement("a")
+			a.AddAttr("href", "/more")
+			a.AddText("read more")
+			return a
+		},
+	}
+	if !Truncate(tag, opts) {
+		t.Fatal("not truncated")
+	}
+
+	buf := bytes.NewBufferString("")
+	tag.BuildHTML(buf)
+	if v := buf.String(); v != `<div><p>one two</p></div><a href="/more">read more</a>` {
+		t.Error("unexpected", v)
+	}
+}
+
+func TestTruncate_notTruncated(t *testing.T) {
+	tag, err := NewConverter().Parse(strings.NewReader(`<p>short</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if Truncate(tag, NewTruncateOptions(5)) {
+		t.Error("unexpected truncation")
+	}
+}
+
+func TestNextGraphemeBoundary(t *testing.T) {
+	for s, expected := range map[string]int{
+		"éx":    3,
+		"🇯🇵🇺🇸":   8,
+		"👨‍👩‍👧x": 18,
+		"👍🏽x":    8,
+		"\r\nx":  2,
+		"あいう":    3,
+		"क्षि":   6,
+	} {
+		if v := nextGraphemeBoundary(s, 0); v != expected {
+			t.Error("unexpected", s, v)
+		}
+	}
+}
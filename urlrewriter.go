@@ -0,0 +1,300 @@
+package html2html
+
+import (
+	"bytes"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// URLAttrs は要素名ごとにURLを値として持つ属性名の一覧
+// see https://html.spec.whatwg.org/multipage/indices.html#attributes-3
+var URLAttrs = map[string][]string{
+	"a":          {"href"},
+	"area":       {"href"},
+	"audio":      {"src"},
+	"blockquote": {"cite"},
+	"button":     {"formaction"},
+	"del":        {"cite"},
+	"embed":      {"src"},
+	"form":       {"action"},
+	"iframe":     {"src"},
+	"img":        {"src", "srcset"},
+	"input":      {"src", "formaction"},
+	"ins":        {"cite"},
+	"link":       {"href"},
+	"object":     {"data"},
+	"q":          {"cite"},
+	"script":     {"src"},
+	"source":     {"src", "srcset"},
+	"track":      {"src"},
+	"video":      {"src", "poster"},
+}
+
+var _ TagAttrsConsumer = &URLRewriter{}
+
+var cssURLPattern = regexp.MustCompile(`(?i)url\(\s*(?:"([^"]*)"|'([^']*)'|([^'"\s)][^)\s]*))\s*\)`)
+
+// URLRewriteFunc は解決済みのURLを受け取り、書き換え後のURLを返す。
+// nil を返した場合その属性は削除される。
+type URLRewriteFunc func(tag Tag, attrKey string, u *url.URL) (*url.URL, error)
+
+// URLRewriter はURLを持つ属性(srcset, style内の url() を含む)を base URL で解決し、コールバックで書き換える。
+// <base href> を見つけるとそれ以降のURLはその値で解決されるので、文書ごとに作ること。
+type URLRewriter struct {
+	Base    *url.URL
+	Rewrite URLRewriteFunc
+
+	baseFixed bool
+}
+
+func NewURLRewriter(base *url.URL, f URLRewriteFunc) *URLRewriter {
+	return &URLRewriter{Base: base, Rewrite: f}
+}
+
+// RewriteURLs は root 以下の全ての要素と <style> の中身のURLを書き換える
+func RewriteURLs(root Tag, rewriter *URLRewriter) error {
+	if err := rewriter.RewriteTag(root); err != nil {
+		return err
+	}
+
+	for _, token := range root.Tokens() {
+		if token.Type() != TypeTagToken {
+			continue
+		}
+		if err := RewriteURLs(token.Tag(), rewriter); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rewriter *URLRewriter) ConsumeAttrs(tag Tag, token html.Token) error {
+	for _, attr := range token.Attr {
+		tag.AddAttr(attr.Key, attr.Val)
+	}
+
+	return rewriter.RewriteTag(tag)
+}
+
+// RewriteTag は tag 自身の属性を書き換える。子要素は辿らない。
+func (rewriter *URLRewriter) RewriteTag(tag Tag) error {
+	if tag.Name() == "base" {
+		// base 自体の href は書き換えず、以降の解決に使う
+		if attr := tag.GetAttr("href"); attr != nil && !rewriter.baseFixed {
+			if u, err := rewriter.resolve(attr.Value); err == nil {
+				rewriter.Base = u
+				rewriter.baseFixed = true
+			}
+		}
+		return nil
+	}
+
+	if tag.Name() == "style" {
+		for _, token := range tag.Tokens() {
+			if token.Type() != TypeTextToken {
+				continue
+			}
+			css, err := rewriter.RewriteCSS(tag, "", token.TextToken().Text())
+			if err != nil {
+				return err
+			}
+			tag.ReplateChildToken(token, CreateTextToken(css))
+		}
+	}
+
+	urlAttrs := URLAttrs[tag.Name()]
+	for _, attr := range tag.Attrs() {
+		var newValue string
+		var err error
+		switch {
+		case attr.Key == "srcset" && containsString(urlAttrs, attr.Key):
+			newValue, err = rewriter.RewriteSrcset(tag, attr.Key, attr.Value)
+		case attr.Key == "style":
+			newValue, err = rewriter.RewriteCSS(tag, attr.Key, attr.Value)
+		case containsString(urlAttrs, attr.Key):
+			var removed bool
+			newValue, removed, err = rewriter.rewriteURL(tag, attr.Key, attr.Value)
+			if err == nil && removed {
+				tag.RemoveAttr(attr.Key)
+				continue
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		attr.Value = newValue
+	}
+
+	return nil
+}
+
+// RewriteURL は1つのURLを書き換える。解釈できないURLはそのまま返す。
+func (rewriter *URLRewriter) RewriteURL(tag Tag, attrKey string, rawURL string) (string, error) {
+	newURL, _, err := rewriter.rewriteURL(tag, attrKey, rawURL)
+	return newURL, err
+}
+
+// RewriteSrcset は srcset の各候補のURLを書き換える。descriptor はそのまま残す。
+// see https://html.spec.whatwg.org/multipage/images.html#parsing-a-srcset-attribute
+func (rewriter *URLRewriter) RewriteSrcset(tag Tag, attrKey string, srcset string) (string, error) {
+	candidates := ParseSrcset(srcset)
+
+	buf := bytes.NewBufferString("")
+	for _, candidate := range candidates {
+		newURL, removed, err := rewriter.rewriteURL(tag, attrKey, candidate.URL)
+		if err != nil {
+			return "", err
+		} else if removed {
+			continue
+		}
+
+		if buf.Len() != 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(newURL)
+		if candidate.Descriptor != "" {
+			buf.WriteString(" ")
+			buf.WriteString(candidate.Descriptor)
+		}
+	}
+
+	return buf.String(), nil
+}
+
+// RewriteCSS は CSS 中の url() を書き換える
+func (rewriter *URLRewriter) RewriteCSS(tag Tag, attrKey string, css string) (string, error) {
+	var rewriteErr error
+	result := cssURLPattern.ReplaceAllStringFunc(css, func(s string) string {
+		if rewriteErr != nil {
+			return s
+		}
+
+		m := cssURLPattern.FindStringSubmatch(s)
+		quote := ""
+		rawURL := m[3]
+		if m[1] != "" {
+			quote, rawURL = `"`, m[1]
+		} else if m[2] != "" {
+			quote, rawURL = `'`, m[2]
+		}
+
+		newURL, removed, err := rewriter.rewriteURL(tag, attrKey, rawURL)
+		if err != nil {
+			rewriteErr = err
+			return s
+		} else if removed {
+			return "none"
+		}
+
+		return "url(" + quote + newURL + quote + ")"
+	})
+	if rewriteErr != nil {
+		return "", rewriteErr
+	}
+
+	return result, nil
+}
+
+func (rewriter *URLRewriter) rewriteURL(tag Tag, attrKey string, rawURL string) (string, bool, error) {
+	u, err := rewriter.resolve(rawURL)
+	if err != nil {
+		// 壊れたURLは触らない
+		return rawURL, false, nil
+	}
+
+	if rewriter.Rewrite != nil {
+		u, err = rewriter.Rewrite(tag, attrKey, u)
+		if err != nil {
+			return "", false, err
+		} else if u == nil {
+			return "", true, nil
+		}
+	}
+
+	return u.String(), false, nil
+}
+
+func (rewriter *URLRewriter) resolve(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return nil, err
+	}
+	if rewriter.Base != nil {
+		u = rewriter.Base.ResolveReference(u)
+	}
+
+	return u, nil
+}
+
+// SrcsetCandidate は srcset の1つの候補
+type SrcsetCandidate struct {
+	URL        string
+	Descriptor string
+}
+
+// ParseSrcset は srcset 属性の値を候補ごとに分解する
+func ParseSrcset(srcset string) []SrcsetCandidate {
+	var candidates []SrcsetCandidate
+
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+
+	pos := 0
+	for pos < len(srcset) {
+		for pos < len(srcset) && (isSpace(srcset[pos]) || srcset[pos] == ',') {
+			pos++
+		}
+		if pos >= len(srcset) {
+			break
+		}
+
+		start := pos
+		for pos < len(srcset) && !isSpace(srcset[pos]) {
+			pos++
+		}
+		rawURL := srcset[start:pos]
+
+		if strings.HasSuffix(rawURL, ",") {
+			// URLの直後のカンマは候補の区切りで、descriptor は無い
+			candidates = append(candidates, SrcsetCandidate{URL: strings.TrimRight(rawURL, ",")})
+			continue
+		}
+
+		start = pos
+		depth := 0
+		for pos < len(srcset) {
+			c := srcset[pos]
+			if c == '(' {
+				depth++
+			} else if c == ')' && depth > 0 {
+				depth--
+			} else if c == ',' && depth == 0 {
+				break
+			}
+			pos++
+		}
+		candidates = append(candidates, SrcsetCandidate{
+			URL:        rawURL,
+			Descriptor: strings.Join(strings.Fields(srcset[start:pos]), " "),
+		})
+	}
+
+	return candidates
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
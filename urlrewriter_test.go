@@ -0,0 +1,81 @@
+package html2html
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestURLRewriter(t *testing.T) {
+	html := `<a href="/about">About</a><img src="a.png" srcset="a.png 1x, b.png 2x"><div style="background: url('bg.png')"></div>`
+
+	base, _ := url.Parse("https://example.com/blog/")
+	rewriter := NewURLRewriter(base, func(tag Tag, attrKey string, u *url.URL) (*url.URL, error) {
+		if tag.Name() == "a" {
+			q := u.Query()
+			q.Set("utm_source", "favclip")
+			u.RawQuery = q.Encode()
+		}
+		return u, nil
+	})
+
+	conv := NewConverter()
+	conv.DefaultConsumer().(*DefaultConsumer).SetTagAttrsConsumer(rewriter)
+
+	result, err := conv.Convert(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `<a href="https://example.com/about?utm_source=favclip">About</a><img src="https://example.com/blog/a.png" srcset="https://example.com/blog/a.png 1x, https://example.com/blog/b.png 2x"><div style="background: url('https://example.com/blog/bg.png')"></div>`
+	if result != expected {
+		t.Log("expected:\n", expected, "actual:\n", result)
+		t.Fail()
+	}
+}
+
+func TestRewriteURLs_baseHref(t *testing.T) {
+	html := `<head><base href="https://cdn.example.com/assets/"><style>p{background:url(p.png)}</style></head><body><img src="x.png"><a href="javascript:alert(1)">x</a></body>`
+
+	conv := NewConverter()
+	tag, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewriter := NewURLRewriter(nil, func(tag Tag, attrKey string, u *url.URL) (*url.URL, error) {
+		if u.Scheme == "javascript" {
+			return nil, nil
+		}
+		return u, nil
+	})
+	if err := RewriteURLs(tag, rewriter); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBufferString("")
+	tag.BuildHTML(buf)
+	expected := `<head><base href="https://cdn.example.com/assets/"><style>p{background:url(https://cdn.example.com/assets/p.png)}</style></head><body><img src="https://cdn.example.com/assets/x.png"><a>x</a></body>`
+	if v := buf.String(); v != expected {
+		t.Log("expected:\n", expected, "actual:\n", v)
+		t.Fail()
+	}
+}
+
+func TestParseSrcset(t *testing.T) {
+	candidates := ParseSrcset("a.png, b.png 2x,  c.png  100w , data:image/png;base64,xyz 3x")
+	expected := []SrcsetCandidate{
+		{URL: "a.png"},
+		{URL: "b.png", Descriptor: "2x"},
+		{URL: "c.png", Descriptor: "100w"},
+		{URL: "data:image/png;base64,xyz", Descriptor: "3x"},
+	}
+	if len(candidates) != len(expected) {
+		t.Fatal("unexpected", candidates)
+	}
+	for idx, candidate := range candidates {
+		if candidate != expected[idx] {
+			t.Error("unexpected", idx, candidate)
+		}
+	}
+}
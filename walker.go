@@ -0,0 +1,109 @@
+package html2html
+
+// WalkAction は Visitor が Walk に次に何をするかを伝える
+type WalkAction int
+
+const (
+	// WalkContinue はそのまま辿り続ける
+	WalkContinue WalkAction = iota
+	// WalkSkipChildren は Enter で返すとそのTokenの子を辿らない
+	WalkSkipChildren
+	// WalkStop は Walk を終える。残りの Leave も呼ばれない
+	WalkStop
+	// WalkRemove はそのTokenを親から取り除く。Enter で返すと子も Leave も辿らない
+	WalkRemove
+	// WalkReplace はそのTokenを一緒に返したTokenで置き換える。Enter で返すと置き換えた後のTokenの子も Leave も辿らない
+	WalkReplace
+)
+
+// Visitor は Walk で全てのTokenに対して呼ばれる。Enter は子より前、Leave は子より後に呼ばれる
+type Visitor interface {
+	Enter(token Token) (WalkAction, Token)
+	Leave(token Token) (WalkAction, Token)
+}
+
+// VisitorFuncs は関数から Visitor を作る。nil の関数は WalkContinue を返す
+type VisitorFuncs struct {
+	EnterFunc func(token Token) (WalkAction, Token)
+	LeaveFunc func(token Token) (WalkAction, Token)
+}
+
+func (funcs *VisitorFuncs) Enter(token Token) (WalkAction, Token) {
+	if funcs.EnterFunc == nil {
+		return WalkContinue, nil
+	}
+	return funcs.EnterFunc(token)
+}
+
+func (funcs *VisitorFuncs) Leave(token Token) (WalkAction, Token) {
+	if funcs.LeaveFunc == nil {
+		return WalkContinue, nil
+	}
+	return funcs.LeaveFunc(token)
+}
+
+// Walk は token とその子孫の全てのTokenを深さ優先で辿る。
+// Visitor の中で木を書き換えても、子を辿り始めた時点の子のうち、まだ取り除かれていないものを辿る。
+// token 自身が取り除かれた場合は nil を、置き換えられた場合は置き換えたTokenを返す。
+func Walk(token Token, visitor Visitor) Token {
+	result, _ := walk(token, visitor)
+	return result
+}
+
+// walk は token の代わりになるTokenと、Walk を終えるかどうかを返す
+func walk(token Token, visitor Visitor) (Token, bool) {
+	action, replacement := visitor.Enter(token)
+	switch action {
+	case WalkStop:
+		return token, true
+	case WalkRemove:
+		return removeToken(token), false
+	case WalkReplace:
+		return replaceToken(token, replacement), false
+	}
+
+	if action != WalkSkipChildren && token.Type() == TypeTagToken {
+		tag := token.Tag()
+		children := append([]Token(nil), tag.Tokens()...)
+		for _, child := range children {
+			if child.Parent() != tag {
+				// 兄弟の処理中に取り除かれたか移動された
+				continue
+			}
+			if _, stop := walk(child, visitor); stop {
+				return token, true
+			}
+		}
+	}
+
+	action, replacement = visitor.Leave(token)
+	switch action {
+	case WalkStop:
+		return token, true
+	case WalkRemove:
+		return removeToken(token), false
+	case WalkReplace:
+		return replaceToken(token, replacement), false
+	}
+
+	return token, false
+}
+
+func removeToken(token Token) Token {
+	if parent := token.Parent(); parent != nil {
+		parent.RemoveChildToken(token)
+	}
+
+	return nil
+}
+
+func replaceToken(token Token, replacement Token) Token {
+	if replacement == nil {
+		return removeToken(token)
+	}
+	if parent := token.Parent(); parent != nil {
+		parent.ReplateChildToken(token, replacement)
+	}
+
+	return replacement
+}
@@ -0,0 +1,99 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	html := `<!-- header --><div><p>Hello <b>world</b></p><script>x()</script><p>Bye</p></div><p>not visited</p>`
+	tag, err := NewConverter().Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []string
+	Walk(tag, &VisitorFuncs{
+		EnterFunc: func(token Token) (WalkAction, Token) {
+			switch token.Type() {
+			case TypeCommentToken:
+				return WalkRemove, nil
+			case TypeTextToken:
+				visited = append(visited, token.TextToken().Text())
+				if token.TextToken().Text() == "Bye" {
+					return WalkStop, nil
+				}
+			case TypeTagToken:
+				switch token.Tag().Name() {
+				case "script":
+					return WalkRemove, nil
+				case "b":
+					strong := CreateElement("strong")
+					strong.SetTokens(token.Tag().Tokens())
+					return WalkReplace, strong
+				}
+			}
+			return WalkContinue, nil
+		},
+		LeaveFunc: func(token Token) (WalkAction, Token) {
+			if token.Type() == TypeTagToken && token.Tag().Name() == "p" {
+				token.Tag().AddAttr("class", "visited")
+			}
+			return WalkContinue, nil
+		},
+	})
+
+	buf := bytes.NewBufferString("")
+	tag.BuildHTML(buf)
+	expected := `<div><p class="visited">Hello <strong>world</strong></p><p>Bye</p></div><p>not visited</p>`
+	if v := buf.String(); v != expected {
+		t.Log("expected:\n", expected, "actual:\n", v)
+		t.Fail()
+	}
+	if strings.Join(visited, "|") != "Hello |Bye" {
+		t.Error("unexpected", visited)
+	}
+}
+
+func TestWalk_skipChildrenAndMutation(t *testing.T) {
+	html := `<ul><li>a</li><li>b</li><li>c</li></ul><pre>skip <b>me</b></pre>`
+	tag, err := NewConverter().Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var visited []string
+	Walk(tag, &VisitorFuncs{
+		EnterFunc: func(token Token) (WalkAction, Token) {
+			if token.Type() != TypeTagToken {
+				return WalkContinue, nil
+			}
+			child := token.Tag()
+			visited = append(visited, child.Name())
+			switch child.Name() {
+			case "pre":
+				return WalkSkipChildren, nil
+			case "li":
+				// 次の兄弟を取り除いても安全に辿れる
+				siblings := child.Parent().Tokens()
+				for idx, sibling := range siblings {
+					if sibling == token && idx+1 < len(siblings) {
+						child.Parent().RemoveChildToken(siblings[idx+1])
+						break
+					}
+				}
+			}
+			return WalkContinue, nil
+		},
+	})
+
+	buf := bytes.NewBufferString("")
+	tag.BuildHTML(buf)
+	if v := buf.String(); v != `<ul><li>a</li><li>c</li></ul><pre>skip <b>me</b></pre>` {
+		t.Error("unexpected", v)
+	}
+	if strings.Join(visited, ",") != ",ul,li,li,pre" {
+		t.Error("unexpected", visited)
+	}
+}
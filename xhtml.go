@@ -0,0 +1,223 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+)
+
+const (
+	XHTMLNamespace  = "http://www.w3.org/1999/xhtml"
+	SVGNamespace    = "http://www.w3.org/2000/svg"
+	MathMLNamespace = "http://www.w3.org/1998/Math/MathML"
+)
+
+// rootNamespaces は名前空間を宣言する要素
+var rootNamespaces = map[string]string{
+	"html": XHTMLNamespace,
+	"svg":  SVGNamespace,
+	"math": MathMLNamespace,
+}
+
+func buildXHTML(buf *bytes.Buffer, tag Tag, opts *BuildOptions) {
+	if tag.IsDocumentRoot() {
+		for _, token := range tag.Tokens() {
+			token.BuildHTMLWithOptions(buf, opts)
+		}
+		return
+	}
+
+	namespace := foreignNamespace(tag)
+	foreign := namespace != ""
+	name := tag.Name()
+	if !foreign {
+		name = strings.ToLower(name)
+	} else if namespace == "svg" {
+		name = adjustForeignName(svgElementNames, name)
+	}
+
+	buf.WriteString("<")
+	buf.WriteString(name)
+	buildXHTMLAttrs(buf, tag, name, namespace, opts)
+
+	void := !foreign && containsString(VoidElements, name)
+	if void || (foreign && len(tag.Tokens()) == 0) {
+		buf.WriteString(" />")
+		return
+	}
+	buf.WriteString(">")
+
+	if name == "script" || name == "style" {
+		buildCDATA(buf, name, tag.TextContent())
+	} else if !tag.IsSelfClosing() {
+		for _, token := range tag.Tokens() {
+			token.BuildHTMLWithOptions(buf, opts)
+		}
+	}
+
+	buf.WriteString("</")
+	buf.WriteString(name)
+	buf.WriteString(">")
+}
+
+func buildXHTMLAttrs(buf *bytes.Buffer, tag Tag, name string, namespace string, opts *BuildOptions) {
+	foreign := namespace != ""
+	var keys []string
+	ns, ok := rootNamespaces[name]
+	if parent := tag.Parent(); !foreign && parent != nil && strings.EqualFold(parent.Name(), "foreignObject") {
+		// foreignObject の中は SVG の名前空間から HTML に戻す
+		ns, ok = XHTMLNamespace, true
+	}
+	if ok && !tag.HasAttr("xmlns") {
+		buf.WriteString(` xmlns="`)
+		buf.WriteString(ns)
+		buf.WriteString(`"`)
+		keys = append(keys, "xmlns")
+	}
+
+	for _, attr := range tag.Attrs() {
+		key := attr.Key
+		switch namespace {
+		case "":
+			key = strings.ToLower(key)
+		case "svg":
+			key = adjustForeignName(svgAttrNames, key)
+		case "math":
+			key = adjustForeignName(mathMLAttrNames, key)
+		}
+		// XML では同じ属性を2度書けない
+		if key == "" || containsString(keys, key) || strings.ContainsAny(key, " \t\n\f\r\"'<>/=") {
+			continue
+		}
+		keys = append(keys, key)
+
+		value := attr.Value
+		if value == "" && !foreign && containsString(BooleanAttributes, key) {
+			value = key
+		}
+
+		buf.WriteString(" ")
+		buf.WriteString(key)
+		buf.WriteString(`="`)
+		buf.WriteString(escapeXML(value, true, opts))
+		buf.WriteString(`"`)
+	}
+}
+
+func buildXHTMLText(buf *bytes.Buffer, textToken TextToken, opts *BuildOptions) {
+	switch textToken.Type() {
+	case TypeDoctypeToken:
+		buf.WriteString("<!DOCTYPE ")
+		buf.WriteString(textToken.Text())
+		buf.WriteString(">")
+	case TypeTextToken:
+		buf.WriteString(escapeXML(textToken.Text(), false, opts))
+	case TypeCommentToken:
+		// XML のコメントには -- を含められず、- で終われない
+		text := textToken.Text()
+		for strings.Contains(text, "--") {
+			text = strings.Replace(text, "--", "- -", -1)
+		}
+		if strings.HasSuffix(text, "-") {
+			text += " "
+		}
+		buf.WriteString("<!--")
+		buf.WriteString(text)
+		buf.WriteString("-->")
+	}
+}
+
+// buildCDATA は script と style の中身を、HTML としてはコメントになる形の CDATA で囲む
+func buildCDATA(buf *bytes.Buffer, name string, text string) {
+	if !strings.ContainsAny(text, "<&") {
+		buf.WriteString(text)
+		return
+	}
+
+	text = strings.Replace(text, "]]>", "]]]]><![CDATA[>", -1)
+	if name == "script" {
+		buf.WriteString("//<![CDATA[\n")
+		buf.WriteString(text)
+		buf.WriteString("\n//]]>")
+	} else {
+		buf.WriteString("/*<![CDATA[*/\n")
+		buf.WriteString(text)
+		buf.WriteString("\n/*]]>*/")
+	}
+}
+
+// escapeXML は XML として必要な文字参照にする。opts.Entities が EntityNumeric なら ASCII 以外の文字も数値文字参照にする
+func escapeXML(text string, attr bool, opts *BuildOptions) string {
+	replacer := xmlTextReplacer
+	if attr {
+		replacer = xmlAttrReplacer
+	}
+	text = replacer.Replace(text)
+
+	if opts != nil && opts.Entities == EntityNumeric {
+		text = encodeNonASCII(text)
+	}
+
+	return text
+}
+
+var xmlTextReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+var xmlAttrReplacer = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;")
+
+// foreignNamespace は tag が svg か math の中(自身を含む)にあれば "svg" か "math" を返す。
+// foreignObject の子は HTML に戻る
+func foreignNamespace(tag Tag) string {
+	for current := tag; current != nil; current = current.Parent() {
+		switch name := strings.ToLower(current.Name()); {
+		case name == "svg" || name == "math":
+			return name
+		case name == "foreignobject" && current != tag:
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// adjustForeignName は Parse で小文字になった SVG, MathML の名前を本来の大文字小文字に戻す
+func adjustForeignName(names map[string]string, name string) string {
+	if adjusted, ok := names[strings.ToLower(name)]; ok {
+		return adjusted
+	}
+
+	return name
+}
+
+func foreignNameTable(names ...string) map[string]string {
+	table := make(map[string]string, len(names))
+	for _, name := range names {
+		table[strings.ToLower(name)] = name
+	}
+
+	return table
+}
+
+// svgElementNames, svgAttrNames, mathMLAttrNames は大文字を含む SVG と MathML の名前
+// see https://html.spec.whatwg.org/multipage/parsing.html#parsing-main-inforeign
+var (
+	svgElementNames = foreignNameTable(
+		"altGlyph", "altGlyphDef", "altGlyphItem", "animateColor", "animateMotion", "animateTransform", "clipPath",
+		"feBlend", "feColorMatrix", "feComponentTransfer", "feComposite", "feConvolveMatrix", "feDiffuseLighting",
+		"feDisplacementMap", "feDistantLight", "feDropShadow", "feFlood", "feFuncA", "feFuncB", "feFuncG", "feFuncR",
+		"feGaussianBlur", "feImage", "feMerge", "feMergeNode", "feMorphology", "feOffset", "fePointLight",
+		"feSpecularLighting", "feSpotLight", "feTile", "feTurbulence", "foreignObject", "glyphRef",
+		"linearGradient", "radialGradient", "textPath",
+	)
+	svgAttrNames = foreignNameTable(
+		"attributeName", "attributeType", "baseFrequency", "baseProfile", "calcMode", "clipPathUnits",
+		"diffuseConstant", "edgeMode", "filterUnits", "glyphRef", "gradientTransform", "gradientUnits",
+		"kernelMatrix", "kernelUnitLength", "keyPoints", "keySplines", "keyTimes", "lengthAdjust",
+		"limitingConeAngle", "markerHeight", "markerUnits", "markerWidth", "maskContentUnits", "maskUnits",
+		"numOctaves", "pathLength", "patternContentUnits", "patternTransform", "patternUnits", "pointsAtX",
+		"pointsAtY", "pointsAtZ", "preserveAlpha", "preserveAspectRatio", "primitiveUnits", "refX", "refY",
+		"repeatCount", "repeatDur", "requiredExtensions", "requiredFeatures", "specularConstant",
+		"specularExponent", "spreadMethod", "startOffset", "stdDeviation", "stitchTiles", "surfaceScale",
+		"systemLanguage", "tableValues", "targetX", "targetY", "textLength", "viewBox", "viewTarget",
+		"xChannelSelector", "yChannelSelector", "zoomAndPan",
+	)
+	mathMLAttrNames = foreignNameTable("definitionURL")
+)
@@ -0,0 +1,60 @@
+package html2html
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestBuildHTMLWithOptions_xhtml(t *testing.T) {
+	html := `<!DOCTYPE html><HTML lang="ja"><head><meta charset="utf-8"><script>if (a < b && c) {}</script><style>p > a { color: red; }</style></head><body><p class="a" class="b">A &amp; B &lt;tag&gt;<br><img src="a.png?x=1&amp;y=2" alt='say "hi"'></p><input type="checkbox" checked disabled><!-- a -- b --><style>a::after { content: "&"; }</style><svg viewBox="0 0 10 10" preserveAspectRatio="none"><linearGradient id="g"></linearGradient><foreignObject><p>x</p></foreignObject><circle r="1"></circle></svg><math><mi definitionURL="u">x</mi></math></body></HTML>`
+
+	conv := NewConverter()
+	root, err := conv.Parse(strings.NewReader(html))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := bytes.NewBufferString("")
+	root.BuildHTMLWithOptions(buf, &BuildOptions{XHTML: true})
+
+	expected := `<!DOCTYPE html><html xmlns="http://www.w3.org/1999/xhtml" lang="ja"><head><meta charset="utf-8" /><script>//<![CDATA[
+if (a < b && c) {}
+//]]></script><style>p > a { color: red; }</style></head><body><p class="a">A &amp; B &lt;tag&gt;<br /><img src="a.png?x=1&amp;y=2" alt="say &quot;hi&quot;" /></p><input type="checkbox" checked="checked" disabled="disabled" /><!-- a - - b --><style>/*<![CDATA[*/
+a::after { content: "&"; }
+/*]]>*/</style><svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 10 10" preserveAspectRatio="none"><linearGradient id="g" /><foreignObject><p xmlns="http://www.w3.org/1999/xhtml">x</p></foreignObject><circle r="1" /></svg><math xmlns="http://www.w3.org/1998/Math/MathML"><mi definitionURL="u">x</mi></math></body></html>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+
+	// nil なら BuildHTML と同じ
+	plain := bytes.NewBufferString("")
+	root.BuildHTMLWithOptions(plain, nil)
+	buf.Reset()
+	root.BuildHTML(buf)
+	if plain.String() != buf.String() {
+		t.Log("expected:\n", buf.String(), "actual:\n", plain.String())
+		t.Fail()
+	}
+}
+
+func TestBuildOptions_Serialize(t *testing.T) {
+	conv := NewConverter()
+	conv.(ConverterOptions).SetSerializer(&BuildOptions{XHTML: true})
+
+	root := CreateDocumentRoot()
+	div := CreateElementSelfClosing("DIV")
+	root.AddChildTokens(div)
+	div.AddAttr("INERT", "")
+
+	buf := bytes.NewBufferString("")
+	if err := conv.(ConverterOptions).Serializer().Serialize(buf, root); err != nil {
+		t.Fatal(err)
+	}
+	expected := `<div inert="inert"></div>`
+	if buf.String() != expected {
+		t.Log("expected:\n", expected, "actual:\n", buf.String())
+		t.Fail()
+	}
+}